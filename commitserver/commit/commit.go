@@ -2,26 +2,105 @@ package commit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"path"
+	"time"
 
 	securejoin "github.com/cyphar/filepath-securejoin"
-	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/argoproj/argo-cd/v2/commitserver/apiclient"
 	"github.com/argoproj/argo-cd/v2/commitserver/metrics"
+	"github.com/argoproj/argo-cd/v2/commitserver/pr"
 	"github.com/argoproj/argo-cd/v2/util/git"
 )
 
+// defaultRepoTimeout bounds how long a single Commit call may spend on git subprocesses against one repo, on top of
+// whatever deadline the incoming gRPC context already carries.
+const defaultRepoTimeout = 5 * time.Minute
+
 type Service struct {
-	gitCredsStore git.CredsStore
-	metricsServer *metrics.Server
+	gitCredsStore     git.CredsStore
+	metricsServer     *metrics.Server
+	signingConfig     *SigningConfig
+	repoSigningConfig map[string]*SigningConfig
+	prConfig          *pr.Config
+	prProvider        pr.Provider
+	workingCopyCache  *workingCopyCache
+	repoTimeout       time.Duration
 }
 
-func NewService(gitCredsStore git.CredsStore, metricsServer *metrics.Server) *Service {
-	return &Service{gitCredsStore: gitCredsStore, metricsServer: metricsServer}
+// ServiceOpts configures optional behavior on a Service. It follows the same pattern as git.ClientOpts.
+type ServiceOpts func(*Service)
+
+// WithSigningConfig configures the Service to sign every commit it produces according to cfg, unless the commit's
+// repo has a more specific override from WithRepoSigningConfig.
+func WithSigningConfig(cfg *SigningConfig) ServiceOpts {
+	return func(s *Service) {
+		s.signingConfig = cfg
+	}
+}
+
+// WithRepoSigningConfig overrides the Service's signing config for commits to repoURL. apiclient.ManifestsRequest is
+// proto-generated in a module this tree doesn't vendor, so it can't carry a SigningConfig field directly; keying the
+// override off RepoUrl, which the request already carries, gets the same per-repo effect without reaching into that
+// type. Passing a nil cfg disables signing for repoURL even if a Service-wide SigningConfig is set.
+func WithRepoSigningConfig(repoURL string, cfg *SigningConfig) ServiceOpts {
+	return func(s *Service) {
+		if s.repoSigningConfig == nil {
+			s.repoSigningConfig = make(map[string]*SigningConfig)
+		}
+		s.repoSigningConfig[repoURL] = cfg
+	}
+}
+
+// signingConfigFor resolves the SigningConfig to use for a commit to repoURL, preferring a per-repo override over
+// the Service-wide default.
+func (s *Service) signingConfigFor(repoURL string) *SigningConfig {
+	if cfg, ok := s.repoSigningConfig[repoURL]; ok {
+		return cfg
+	}
+	return s.signingConfig
+}
+
+// WithPRProvider configures the Service to open (or update) a pull/merge request via provider after every commit it
+// pushes, rendering title/body from cfg's templates. provider is expected to already be wired up with credentials
+// (e.g. a GitHub App installation token source) by the caller.
+func WithPRProvider(cfg pr.Config, provider pr.Provider) ServiceOpts {
+	return func(s *Service) {
+		s.prConfig = &cfg
+		s.prProvider = provider
+	}
+}
+
+// WithWorkingCopyCache configures where the Service caches mirror clones between requests, and how many it
+// retains. If not used, NewService defaults to defaultWorkingCopyCacheRoot and defaultMaxCachedRepos.
+func WithWorkingCopyCache(root string, maxRepos int) ServiceOpts {
+	return func(s *Service) {
+		s.workingCopyCache = NewWorkingCopyCache(root, maxRepos)
+	}
+}
+
+// WithRepoTimeout bounds how long Commit's git subprocesses may run against a single repo, in addition to whatever
+// deadline the incoming gRPC context already carries. If not used, NewService defaults to defaultRepoTimeout.
+func WithRepoTimeout(timeout time.Duration) ServiceOpts {
+	return func(s *Service) {
+		s.repoTimeout = timeout
+	}
+}
+
+func NewService(gitCredsStore git.CredsStore, metricsServer *metrics.Server, opts ...ServiceOpts) *Service {
+	s := &Service{
+		gitCredsStore:    gitCredsStore,
+		metricsServer:    metricsServer,
+		workingCopyCache: NewWorkingCopyCache(defaultWorkingCopyCacheRoot, defaultMaxCachedRepos),
+		repoTimeout:      defaultRepoTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Service) Commit(ctx context.Context, r *apiclient.ManifestsRequest) (*apiclient.ManifestsResponse, error) {
@@ -35,38 +114,31 @@ func (s *Service) Commit(ctx context.Context, r *apiclient.ManifestsRequest) (*a
 
 	logCtx := log.WithFields(log.Fields{"repo": r.RepoUrl, "branch": r.TargetBranch, "drySHA": r.DrySha})
 
-	dirPath, cleanup, err := makeSecureTempDir()
-	if err != nil {
-		logCtx.WithError(err).Error("failed to create temp dir")
-		return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to create temp dir: %w", err)
-	}
-	defer func() {
-		err := cleanup()
-		if err != nil {
-			logCtx.WithError(err).Error("failed to clean up temp dir")
-		}
-	}()
+	// Serialize commits to the same repo, since they share a cached mirror clone. This blocks on a plain mutex, not
+	// ctx, so it must happen before the repoTimeout below starts counting down; otherwise a request queued behind a
+	// slow sibling commit would spend its whole timeout budget just waiting for the lock and then fail with a
+	// misleading "timed out committing and pushing", despite no git subprocess ever running slowly.
+	unlockRepo := s.workingCopyCache.LockRepo(r.RepoUrl)
+	defer unlockRepo()
+
+	// Bound every git subprocess below by repoTimeout, on top of whatever deadline the incoming gRPC context already
+	// carries, so a wedged git/ssh/credential-helper process can't hold the repo's lock forever.
+	ctx, cancel := context.WithTimeout(ctx, s.repoTimeout)
+	defer cancel()
 
 	gitCreds := r.Repo.GetGitCreds(s.gitCredsStore)
 	opts := git.WithEventHandlers(metrics.NewGitClientEventHandlers(s.metricsServer))
-	gitClient, err := git.NewClientExt(r.RepoUrl, dirPath, gitCreds, r.Repo.IsInsecure(), r.Repo.IsLFSEnabled(), r.Repo.Proxy, opts)
-	if err != nil {
-		logCtx.WithError(err).Error("failed to create git client")
-		return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to create git client: %w", err)
-	}
-
-	err = gitClient.Init()
-	if err != nil {
-		logCtx.WithError(err).Error("failed to initialize git client")
-		return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to init git client: %w", err)
-	}
-
-	// Clone the repo into the temp dir using the git CLI
-	logCtx.Debugf("Cloning repo %s", r.RepoUrl)
-	err = gitClient.Fetch("")
+	gitClient, dirPath, cleanupWorktree, err := s.workingCopyCache.PrepareWorktree(
+		ctx, logCtx, r.RepoUrl, gitCreds, r.Repo.IsInsecure(), r.Repo.IsLFSEnabled(), r.Repo.Proxy, opts)
 	if err != nil {
-		return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to clone repo: %w", err)
+		logCtx.WithError(err).Error("failed to prepare working copy")
+		return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to prepare working copy: %w", err)
 	}
+	defer func() {
+		if err := cleanupWorktree(); err != nil {
+			logCtx.WithError(err).Error("failed to clean up worktree")
+		}
+	}()
 
 	// TODO: Produce metrics on getting user info, since it'll generally hit APIs. Make sure to label by _which_ API is
 	//       being hit.
@@ -84,7 +156,7 @@ func (s *Service) Commit(ctx context.Context, r *apiclient.ManifestsRequest) (*a
 
 	// Checkout the sync branch
 	logCtx.Debugf("Checking out sync branch %s", r.SyncBranch)
-	out, err = gitClient.CheckoutOrOrphan(r.SyncBranch, false)
+	out, err = gitClient.CheckoutOrOrphan(ctx, r.SyncBranch, false)
 	if err != nil {
 		logCtx.WithError(err).WithField("output", out).Error("failed to checkout sync branch")
 		return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to checkout sync branch: %w", err)
@@ -92,34 +164,72 @@ func (s *Service) Commit(ctx context.Context, r *apiclient.ManifestsRequest) (*a
 
 	// Checkout the target branch
 	logCtx.Debugf("Checking out target branch %s", r.TargetBranch)
-	out, err = gitClient.CheckoutOrNew(r.TargetBranch, r.SyncBranch, false)
+	out, err = gitClient.CheckoutOrNew(ctx, r.TargetBranch, r.SyncBranch, false)
 	if err != nil {
 		logCtx.WithError(err).WithField("output", out).Error("failed to checkout target branch")
 		return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to checkout target branch: %w", err)
 	}
 
+	h := newHydratorHelper(dirPath)
+
+	// Snapshot each path's resources before clearing the repo, so we can compute a diff summary against the newly
+	// written tree below.
+	previousResources := make(map[string]map[resourceRef]string, len(r.Paths))
+	for _, p := range r.Paths {
+		hydratePath := p.Path
+		if hydratePath == "." {
+			hydratePath = ""
+		}
+		resources, err := h.ReadExistingResources(hydratePath)
+		if err != nil {
+			logCtx.WithError(err).Error("failed to read existing resources for diff summary")
+			return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to read existing resources for diff summary: %w", err)
+		}
+		previousResources[hydratePath] = resources
+	}
+
 	// Clear the repo contents using git rm
 	logCtx.Debug("Clearing repo contents")
-	out, err = gitClient.RemoveContents()
+	out, err = gitClient.RemoveContents(ctx)
 	if err != nil {
 		logCtx.WithError(err).WithField("output", out).Error("failed to clear repo")
 		return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to clear repo: %w", err)
 	}
 
-	// TODO: abstract out the "write to disk" part - it's making this function too long.
+	// Configure commit signing, if enabled. This must happen before any hydrator metadata is written, since the
+	// signing key is resolved up front and recorded in that metadata.
+	signingConfig := s.signingConfigFor(r.RepoUrl)
+	signer, err := newCommitSigner(signingConfig)
+	if err != nil {
+		logCtx.WithError(err).Error("failed to initialize commit signer")
+		return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to initialize commit signer: %w", err)
+	}
+	var signatureMetadata *SignatureMetadata
+	if signer != nil {
+		logCtx.Debugf("Configuring %s commit signing", signingConfig.Mode)
+		var signerCleanup func()
+		signatureMetadata, signerCleanup, err = signer.configure(ctx, dirPath)
+		if err != nil {
+			logCtx.WithError(err).Error("failed to configure commit signing")
+			return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to configure commit signing: %w", err)
+		}
+		defer signerCleanup()
+	}
 
-	h := newHydratorHelper(dirPath)
+	// TODO: abstract out the "write to disk" part - it's making this function too long.
 
 	// Write hydrator.metadata containing information about the hydration process. This top-level metadata file is used
 	// for the promoter. An additional metadata file is placed in each hydration destination directory, if applicable.
 	logCtx.Debug("Writing top-level hydrator metadata")
-	err = h.WriteMetadata(hydratorMetadataFile{DrySHA: r.DrySha, RepoURL: r.RepoUrl}, "")
+	err = h.WriteMetadata(hydratorMetadataFile{DrySHA: r.DrySha, RepoURL: r.RepoUrl, SigningMetadata: signatureMetadata}, "")
 	if err != nil {
 		logCtx.WithError(err).Error("failed to write top-level hydrator metadata")
 		return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to write top-level hydrator metadata: %w", err)
 	}
 
 	// Write the manifests to the temp dir
+	var allCommands []string
+	var overallDiff DiffSummary
 	for _, p := range r.Paths {
 		hydratePath := p.Path
 		if hydratePath == "." {
@@ -139,19 +249,34 @@ func (s *Service) Commit(ctx context.Context, r *apiclient.ManifestsRequest) (*a
 		}
 
 		// Write the manifests
-		err = h.WriteManifests(p.Manifests, hydratePath)
+		err = h.WriteManifests(p.Manifests, OutputFormat(p.OutputFormat), hydratePath)
 		if err != nil {
 			logCtx.WithError(err).Error("failed to write manifests")
 			return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to write manifests: %w", err)
 		}
 
+		newResources, err := h.ReadExistingResources(hydratePath)
+		if err != nil {
+			logCtx.WithError(err).Error("failed to read newly written resources for diff summary")
+			return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to read newly written resources for diff summary: %w", err)
+		}
+		diff := DiffResources(previousResources[hydratePath], newResources)
+		overallDiff.Added = append(overallDiff.Added, diff.Added...)
+		overallDiff.Modified = append(overallDiff.Modified, diff.Modified...)
+		overallDiff.Removed = append(overallDiff.Removed, diff.Removed...)
+
 		// Write hydrator.metadata containing information about the hydration process.
 		logCtx.Debug("Writing hydrator metadata")
 		hydratorMetadata := hydratorMetadataFile{
-			Commands: p.Commands,
-			DrySHA:   r.DrySha,
-			RepoURL:  r.RepoUrl,
+			Commands:        p.Commands,
+			DrySHA:          r.DrySha,
+			RepoURL:         r.RepoUrl,
+			SigningMetadata: signatureMetadata,
+		}
+		if !diff.Empty() {
+			hydratorMetadata.Diff = &diff
 		}
+		allCommands = append(allCommands, p.Commands...)
 		err = h.WriteMetadata(hydratorMetadata, hydratePath)
 		if err != nil {
 			logCtx.WithError(err).Error("failed to write hydrator metadata")
@@ -167,48 +292,95 @@ func (s *Service) Commit(ctx context.Context, r *apiclient.ManifestsRequest) (*a
 		}
 	}
 
+	commitMessage := r.CommitMessage
+	if !overallDiff.Empty() {
+		diffSummaryText, err := overallDiff.Render()
+		if err != nil {
+			logCtx.WithError(err).Error("failed to render diff summary")
+			return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to render diff summary: %w", err)
+		}
+		commitMessage += "\n" + diffSummaryText
+	}
+
 	// Commit the changes
 	logCtx.Debugf("Committing and pushing changes")
-	out, err = gitClient.CommitAndPush(r.TargetBranch, r.CommitMessage)
+	out, err = gitClient.CommitAndPush(ctx, r.TargetBranch, commitMessage)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			logCtx.WithError(err).WithField("output", out).Error("timed out committing and pushing")
+			return &apiclient.ManifestsResponse{}, fmt.Errorf("timed out committing and pushing: %w", err)
+		}
 		logCtx.WithError(err).WithField("output", out).Error("failed to commit and push")
 		return &apiclient.ManifestsResponse{}, fmt.Errorf("failed to commit and push: %w", err)
 	}
 
 	logCtx.WithField("output", out).Debug("pushed manifests to git")
 
+	if signer != nil {
+		logCtx.WithField("signature", signatureMetadata).Debug("signed commit")
+	}
+
+	if s.prProvider != nil {
+		if err := s.ensurePullRequest(ctx, logCtx, r, allCommands); err != nil {
+			// Pull request creation is best-effort: the hydrated manifests are already pushed, so we log and move
+			// on rather than failing the whole request.
+			logCtx.WithError(err).Error("failed to open or update pull request for hydrated manifests")
+		}
+	}
+
 	return &apiclient.ManifestsResponse{}, nil
 }
 
-// makeSecureTempDir creates a secure temporary directory and returns the path to the directory. The path is "secure" in
-// the sense that its name is a UUID, which helps mitigate path traversal attacks. The function also returns a cleanup
-// function that should be used to remove the directory when it is no longer needed.
-func makeSecureTempDir() (string, func() error, error) {
-	// The UUID is an important security mechanism to help mitigate path traversal attacks.
-	dirName, err := uuid.NewRandom()
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to generate uuid: %w", err)
+// ensurePullRequest opens (or updates) a pull/merge request from the target branch into the configured base branch,
+// using the Service's configured pr.Provider. It's a no-op unless WithPRProvider was used to configure the Service.
+func (s *Service) ensurePullRequest(ctx context.Context, logCtx *log.Entry, r *apiclient.ManifestsRequest, commands []string) error {
+	if s.prProvider == nil || s.prConfig == nil {
+		return nil
+	}
+	base := s.prConfig.BaseBranch
+	if base == "" {
+		base = r.SyncBranch
 	}
-	// Don't need SecureJoin here, both parts are safe.
-	dirPath := path.Join("/tmp/_commit-service", dirName.String())
-	err = os.MkdirAll(dirPath, os.ModePerm)
+
+	title, body, err := s.prConfig.Render(pr.TemplateFields{
+		RepoURL:      r.RepoUrl,
+		DrySHA:       r.DrySha,
+		TargetBranch: r.TargetBranch,
+		BaseBranch:   base,
+		Commands:     commands,
+	})
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to create temp dir: %w", err)
+		return fmt.Errorf("failed to render pull request templates: %w", err)
 	}
-	cleanup := func() error {
-		err := os.RemoveAll(dirPath)
-		if err != nil {
-			return fmt.Errorf("failed to remove temp dir: %w", err)
-		}
-		return nil
+
+	result, err := s.prProvider.EnsurePullRequest(ctx, pr.Request{
+		Repo:      r.Repo.Repo,
+		Head:      r.TargetBranch,
+		Base:      base,
+		Title:     title,
+		Body:      body,
+		AutoMerge: s.prConfig.AutoMerge,
+	})
+	if err != nil {
+		return err
 	}
-	return dirPath, cleanup, nil
+
+	logCtx.WithFields(log.Fields{"prID": result.ID, "prURL": result.URL, "prCreated": result.Created}).
+		Debug("ensured pull request for hydrated manifests")
+	return nil
 }
 
 type hydratorMetadataFile struct {
 	Commands []string `json:"commands"`
 	RepoURL  string   `json:"repoURL"`
 	DrySHA   string   `json:"drySha"`
+	// SigningMetadata describes the signature attached to the hydrated commit, if commit signing was configured.
+	SigningMetadata *SignatureMetadata `json:"signingMetadata,omitempty"`
+	// Diff summarizes how this path's resources changed relative to the previous commit on the target branch. It is
+	// nil (and omitted from the marshaled JSON) when nothing changed, or for the top-level metadata file, which
+	// aggregates across paths and never has a diff of its own. DiffSummary's own fields are all omitempty slices, so
+	// a non-pointer field here would still always marshal as "diff":{} instead of being left out entirely.
+	Diff *DiffSummary `json:"diff,omitempty"`
 }
 
 var manifestHydrationReadmeTemplate = `
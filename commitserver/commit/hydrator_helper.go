@@ -0,0 +1,342 @@
+package commit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"sigs.k8s.io/yaml"
+
+	"github.com/argoproj/argo-cd/v2/commitserver/apiclient"
+)
+
+// OutputFormat selects how a PathDetails' hydrated manifests are laid out on disk.
+type OutputFormat string
+
+const (
+	// OutputFormatManifestFile writes every manifest into a single manifest.yaml. This is the original (and
+	// default) layout.
+	OutputFormatManifestFile OutputFormat = ""
+	// OutputFormatSplitPerResource writes one file per resource, named <namespace>_<kind>_<apiVersion>_<name>.yaml.
+	OutputFormatSplitPerResource OutputFormat = "split"
+	// OutputFormatKustomize writes one file per resource plus a kustomization.yaml referencing them, so the
+	// hydrated output can be consumed as a Kustomize base.
+	OutputFormatKustomize OutputFormat = "kustomize"
+)
+
+type hydratorHelper struct {
+	repoDir string
+}
+
+func newHydratorHelper(repoDir string) *hydratorHelper {
+	return &hydratorHelper{repoDir: repoDir}
+}
+
+// resourceRef identifies a resource by GVK + namespace/name, independent of field ordering or formatting, so it can
+// be used as a map key when diffing the old and new hydrated trees.
+type resourceRef struct {
+	apiVersion string
+	kind       string
+	namespace  string
+	name       string
+}
+
+func (r resourceRef) String() string {
+	if r.namespace == "" {
+		return fmt.Sprintf("%s/%s %s", r.apiVersion, r.kind, r.name)
+	}
+	return fmt.Sprintf("%s/%s %s/%s", r.apiVersion, r.kind, r.namespace, r.name)
+}
+
+// fileName derives a per-resource file name from the full resourceRef, including apiVersion: two resources that
+// differ only in apiVersion (e.g. a CRD mid-version-bump) must not collide and silently overwrite each other.
+func (r resourceRef) fileName() string {
+	namespace := r.namespace
+	if namespace == "" {
+		namespace = "_cluster"
+	}
+	apiVersion := strings.ReplaceAll(r.apiVersion, "/", "_")
+	if apiVersion == "" {
+		apiVersion = "_core"
+	}
+	return fmt.Sprintf("%s_%s_%s_%s.yaml", namespace, strings.ToLower(r.kind), strings.ToLower(apiVersion), r.name)
+}
+
+type resourceMeta struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+}
+
+func (m resourceMeta) ref() resourceRef {
+	return resourceRef{apiVersion: m.APIVersion, kind: m.Kind, namespace: m.Metadata.Namespace, name: m.Metadata.Name}
+}
+
+// WriteManifests writes manifests to hydratePath according to format, replacing whatever was already written there
+// in this request (the caller is expected to have already cleared the repo contents via git.Client.RemoveContents).
+func (h *hydratorHelper) WriteManifests(manifests []*apiclient.HydratedManifestDetails, format OutputFormat, hydratePath string) error {
+	refs, err := manifestRefs(manifests)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case OutputFormatSplitPerResource:
+		return h.writePerResourceFiles(refs, hydratePath)
+	case OutputFormatKustomize:
+		return h.writeKustomizeOverlay(refs, hydratePath)
+	default:
+		return h.writeManifestFile(manifests, hydratePath)
+	}
+}
+
+func (h *hydratorHelper) writeManifestFile(manifests []*apiclient.HydratedManifestDetails, hydratePath string) error {
+	fullHydratePath, err := securejoin.SecureJoin(h.repoDir, hydratePath)
+	if err != nil {
+		return fmt.Errorf("failed to construct hydrate path: %w", err)
+	}
+
+	var manifestsYAML bytes.Buffer
+	for _, m := range manifests {
+		obj, err := yaml.JSONToYAML([]byte(m.ManifestJSON))
+		if err != nil {
+			return fmt.Errorf("failed to convert manifest to yaml: %w", err)
+		}
+		manifestsYAML.WriteString("---\n")
+		manifestsYAML.Write(obj)
+	}
+
+	manifestFile, err := securejoin.SecureJoin(fullHydratePath, "manifest.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to construct manifest file path: %w", err)
+	}
+	if err := os.WriteFile(manifestFile, manifestsYAML.Bytes(), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to write manifest file: %w", err)
+	}
+	return nil
+}
+
+func (h *hydratorHelper) writePerResourceFiles(refs map[resourceRef]string, hydratePath string) error {
+	fullHydratePath, err := securejoin.SecureJoin(h.repoDir, hydratePath)
+	if err != nil {
+		return fmt.Errorf("failed to construct hydrate path: %w", err)
+	}
+
+	seen := make(map[string]resourceRef, len(refs))
+	for ref := range refs {
+		name := ref.fileName()
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("resource file name collision: %s and %s both map to %s", other, ref, name)
+		}
+		seen[name] = ref
+	}
+
+	for ref, content := range refs {
+		resourceFile, err := securejoin.SecureJoin(fullHydratePath, ref.fileName())
+		if err != nil {
+			return fmt.Errorf("failed to construct resource file path for %s: %w", ref, err)
+		}
+		if err := os.WriteFile(resourceFile, []byte(content), os.ModePerm); err != nil {
+			return fmt.Errorf("failed to write resource file for %s: %w", ref, err)
+		}
+	}
+	return nil
+}
+
+func (h *hydratorHelper) writeKustomizeOverlay(refs map[resourceRef]string, hydratePath string) error {
+	if err := h.writePerResourceFiles(refs, hydratePath); err != nil {
+		return err
+	}
+
+	fileNames := make([]string, 0, len(refs))
+	for ref := range refs {
+		fileNames = append(fileNames, ref.fileName())
+	}
+	sort.Strings(fileNames)
+
+	var kustomization bytes.Buffer
+	kustomization.WriteString("apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n")
+	for _, name := range fileNames {
+		kustomization.WriteString("- " + name + "\n")
+	}
+
+	fullHydratePath, err := securejoin.SecureJoin(h.repoDir, hydratePath)
+	if err != nil {
+		return fmt.Errorf("failed to construct hydrate path: %w", err)
+	}
+	kustomizationFile, err := securejoin.SecureJoin(fullHydratePath, "kustomization.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to construct kustomization file path: %w", err)
+	}
+	if err := os.WriteFile(kustomizationFile, kustomization.Bytes(), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to write kustomization.yaml: %w", err)
+	}
+	return nil
+}
+
+func manifestRefs(manifests []*apiclient.HydratedManifestDetails) (map[resourceRef]string, error) {
+	refs := make(map[resourceRef]string, len(manifests))
+	for _, m := range manifests {
+		obj, err := yaml.JSONToYAML([]byte(m.ManifestJSON))
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert manifest to yaml: %w", err)
+		}
+		var meta resourceMeta
+		if err := yaml.Unmarshal(obj, &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest metadata: %w", err)
+		}
+		refs[meta.ref()] = string(obj)
+	}
+	return refs, nil
+}
+
+// ReadExistingResources parses whatever hydrated manifests are currently on disk at hydratePath - in any of the
+// OutputFormat layouts WriteManifests produces - into a ref->raw-content map, so callers can diff against them
+// before overwriting. It's safe to call on a path that doesn't exist yet or holds no YAML files.
+func (h *hydratorHelper) ReadExistingResources(hydratePath string) (map[resourceRef]string, error) {
+	fullPath, err := securejoin.SecureJoin(h.repoDir, hydratePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct hydrate path: %w", err)
+	}
+
+	entries, err := os.ReadDir(fullPath)
+	if os.IsNotExist(err) {
+		return map[resourceRef]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hydrate path: %w", err)
+	}
+
+	resources := map[resourceRef]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yaml") || entry.Name() == "kustomization.yaml" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(fullPath, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+		for _, doc := range bytes.Split(content, []byte("\n---\n")) {
+			var meta resourceMeta
+			if err := yaml.Unmarshal(doc, &meta); err != nil || meta.Kind == "" {
+				continue
+			}
+			resources[meta.ref()] = string(doc)
+		}
+	}
+	return resources, nil
+}
+
+// DiffSummary counts how a hydrated path's resources changed relative to the previous commit on the target branch,
+// so reviewers of the hydrated-manifests PR can see what changed without rendering diffs locally.
+type DiffSummary struct {
+	Added    []string `json:"added,omitempty"`
+	Modified []string `json:"modified,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+}
+
+// Empty reports whether the diff touched no resources at all.
+func (d DiffSummary) Empty() bool {
+	return len(d.Added) == 0 && len(d.Modified) == 0 && len(d.Removed) == 0
+}
+
+// DiffResources compares the previous and new resource sets for a hydrated path.
+func DiffResources(previous, current map[resourceRef]string) DiffSummary {
+	var summary DiffSummary
+	for ref, newContent := range current {
+		oldContent, existed := previous[ref]
+		switch {
+		case !existed:
+			summary.Added = append(summary.Added, ref.String())
+		case oldContent != newContent:
+			summary.Modified = append(summary.Modified, ref.String())
+		}
+	}
+	for ref := range previous {
+		if _, stillPresent := current[ref]; !stillPresent {
+			summary.Removed = append(summary.Removed, ref.String())
+		}
+	}
+	sort.Strings(summary.Added)
+	sort.Strings(summary.Modified)
+	sort.Strings(summary.Removed)
+	return summary
+}
+
+var diffSummaryTemplate = template.Must(template.New("diffSummary").Parse(`
+## Diff Summary
+
+{{ len .Added }} added, {{ len .Modified }} modified, {{ len .Removed }} removed
+{{- range .Added }}
++ {{ . }}
+{{- end }}
+{{- range .Modified }}
+~ {{ . }}
+{{- end }}
+{{- range .Removed }}
+- {{ . }}
+{{- end }}
+`))
+
+// Render renders the diff summary as commit-message-friendly text.
+func (d DiffSummary) Render() (string, error) {
+	var buf bytes.Buffer
+	if err := diffSummaryTemplate.Execute(&buf, d); err != nil {
+		return "", fmt.Errorf("failed to render diff summary: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// WriteMetadata writes hydrator.metadata containing information about the hydration process to hydratePath.
+func (h *hydratorHelper) WriteMetadata(metadata hydratorMetadataFile, hydratePath string) error {
+	fullHydratePath, err := securejoin.SecureJoin(h.repoDir, hydratePath)
+	if err != nil {
+		return fmt.Errorf("failed to construct hydrate path: %w", err)
+	}
+	metadataFile, err := securejoin.SecureJoin(fullHydratePath, "hydrator.metadata")
+	if err != nil {
+		return fmt.Errorf("failed to construct metadata file path: %w", err)
+	}
+	metadataJSON, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal hydrator metadata: %w", err)
+	}
+	if err := os.WriteFile(metadataFile, metadataJSON, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to write hydrator metadata: %w", err)
+	}
+	return nil
+}
+
+// WriteReadme renders manifestHydrationReadmeTemplate against metadata and writes it to hydratePath.
+func (h *hydratorHelper) WriteReadme(metadata hydratorMetadataFile, hydratePath string) error {
+	fullHydratePath, err := securejoin.SecureJoin(h.repoDir, hydratePath)
+	if err != nil {
+		return fmt.Errorf("failed to construct hydrate path: %w", err)
+	}
+	readmeFile, err := securejoin.SecureJoin(fullHydratePath, "README.md")
+	if err != nil {
+		return fmt.Errorf("failed to construct readme file path: %w", err)
+	}
+
+	tmpl, err := template.New("readme").Parse(manifestHydrationReadmeTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse readme template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, metadata); err != nil {
+		return fmt.Errorf("failed to execute readme template: %w", err)
+	}
+	if err := os.WriteFile(readmeFile, buf.Bytes(), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to write readme: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package commit
+
+import (
+	"testing"
+
+	"github.com/argoproj/argo-cd/v2/commitserver/apiclient"
+)
+
+func TestDiffResources_ClassifiesAddedModifiedRemoved(t *testing.T) {
+	unchanged := resourceRef{kind: "ConfigMap", name: "unchanged"}
+	modified := resourceRef{kind: "ConfigMap", name: "modified"}
+	added := resourceRef{kind: "ConfigMap", name: "added"}
+	removed := resourceRef{kind: "ConfigMap", name: "removed"}
+
+	previous := map[resourceRef]string{
+		unchanged: "same content",
+		modified:  "old content",
+		removed:   "removed content",
+	}
+	current := map[resourceRef]string{
+		unchanged: "same content",
+		modified:  "new content",
+		added:     "added content",
+	}
+
+	summary := DiffResources(previous, current)
+
+	if got, want := summary.Added, []string{added.String()}; !equalStrings(got, want) {
+		t.Errorf("Added = %v, want %v", got, want)
+	}
+	if got, want := summary.Modified, []string{modified.String()}; !equalStrings(got, want) {
+		t.Errorf("Modified = %v, want %v", got, want)
+	}
+	if got, want := summary.Removed, []string{removed.String()}; !equalStrings(got, want) {
+		t.Errorf("Removed = %v, want %v", got, want)
+	}
+}
+
+func TestDiffResources_EmptyWhenNothingChanged(t *testing.T) {
+	ref := resourceRef{kind: "ConfigMap", name: "unchanged"}
+	previous := map[resourceRef]string{ref: "same content"}
+	current := map[resourceRef]string{ref: "same content"}
+
+	summary := DiffResources(previous, current)
+
+	if !summary.Empty() {
+		t.Errorf("DiffResources() = %+v, want an empty summary", summary)
+	}
+}
+
+func TestResourceRef_FileNameIncludesAPIVersion(t *testing.T) {
+	v1 := resourceRef{apiVersion: "example.com/v1", kind: "Widget", namespace: "ns", name: "thing"}
+	v2 := resourceRef{apiVersion: "example.com/v2", kind: "Widget", namespace: "ns", name: "thing"}
+
+	if v1.fileName() == v2.fileName() {
+		t.Errorf("fileName() collided for two resources differing only in apiVersion: %s", v1.fileName())
+	}
+}
+
+func TestHydratorHelper_WritePerResourceFilesErrorsOnFileNameCollision(t *testing.T) {
+	h := newHydratorHelper(t.TempDir())
+
+	// Two refs that happen to produce the same sanitized file name (apiVersion "a/b" and "a_b" both sanitize to
+	// "a_b") must be rejected rather than one silently overwriting the other on disk.
+	refs := map[resourceRef]string{
+		{apiVersion: "a/b", kind: "Widget", name: "thing"}: "content-1",
+		{apiVersion: "a_b", kind: "Widget", name: "thing"}: "content-2",
+	}
+
+	if err := h.writePerResourceFiles(refs, "."); err == nil {
+		t.Fatal("writePerResourceFiles() did not error on a file name collision")
+	}
+}
+
+func TestHydratorHelper_WriteAndReadExistingResourcesRoundTrips(t *testing.T) {
+	repoDir := t.TempDir()
+	h := newHydratorHelper(repoDir)
+
+	manifests := []*apiclient.HydratedManifestDetails{
+		{ManifestJSON: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"ns"}}`},
+		{ManifestJSON: `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"b","namespace":"ns"}}`},
+	}
+
+	if err := h.WriteManifests(manifests, OutputFormatSplitPerResource, "."); err != nil {
+		t.Fatalf("WriteManifests() returned error: %v", err)
+	}
+
+	resources, err := h.ReadExistingResources(".")
+	if err != nil {
+		t.Fatalf("ReadExistingResources() returned error: %v", err)
+	}
+	if len(resources) != 2 {
+		t.Fatalf("ReadExistingResources() returned %d resources, want 2", len(resources))
+	}
+
+	configMap := resourceRef{apiVersion: "v1", kind: "ConfigMap", namespace: "ns", name: "a"}
+	if _, ok := resources[configMap]; !ok {
+		t.Errorf("ReadExistingResources() is missing %s", configMap)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
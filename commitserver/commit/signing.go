@@ -0,0 +1,248 @@
+package commit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/argoproj/argo-cd/v2/util/git"
+)
+
+// SigningMode selects how (or whether) commits produced by the commit server are signed.
+type SigningMode string
+
+const (
+	SigningModeNone SigningMode = ""
+	SigningModeGPG  SigningMode = "gpg"
+	SigningModeSSH  SigningMode = "ssh"
+)
+
+// SigningConfig configures commit signing for the commit server. It is attached to the Service via
+// WithSigningConfig and applies to every commit produced by that Service instance, except commits to a repo that
+// has its own config set via WithRepoSigningConfig.
+//
+// TODO(follow-up): add a third, keyless SigningModeSigstore backed by Fulcio/Rekor (OIDC token minted for the
+// commit-server's workload identity, signature/certificate recorded as a git note under refs/notes/gitsign, and
+// SignatureMetadata extended with a cert chain digest and Rekor log index). That OIDC/Fulcio/Rekor exchange is a
+// substantial feature on its own, so only GPG and SSH signing ship in this series; Sigstore is tracked separately
+// rather than landing half-finished here.
+type SigningConfig struct {
+	Mode SigningMode
+	GPG  *GPGSigningConfig
+	SSH  *SSHSigningConfig
+}
+
+// GPGSigningConfig signs commits using a GPG key ring imported into a scratch GNUPGHOME for the duration of the
+// request.
+type GPGSigningConfig struct {
+	// KeyRing is the armored private key ring used to sign commits.
+	KeyRing []byte
+	// KeyID is the fingerprint or key ID passed to user.signingkey. If empty, the first secret key in KeyRing is
+	// used.
+	KeyID string
+}
+
+// SSHSigningConfig signs commits using gpg.format=ssh, per GitHub's SSH commit signature verification docs.
+type SSHSigningConfig struct {
+	// PrivateKey is the SSH private key used to sign commits.
+	PrivateKey []byte
+	// AllowedSignersFile is mounted into the commit server and passed as gpg.ssh.allowedSignersFile so that
+	// verification (and `git log --show-signature`) works without additional configuration.
+	AllowedSignersFile string
+}
+
+// SignatureMetadata records what signing produced for a commit, so it can be surfaced to the promoter via
+// hydratorMetadataFile without requiring it to re-parse the commit object.
+type SignatureMetadata struct {
+	Mode SigningMode `json:"mode"`
+	// Fingerprint is the GPG or SSH key fingerprint that produced the signature.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// commitSigner prepares a working copy so that the next `git commit` is signed. The signing key is resolved up
+// front, so SignatureMetadata is available before the commit is made and can be written into hydratorMetadataFile
+// alongside it.
+type commitSigner interface {
+	// configure sets up repo-local git config (and any scratch files) so the subsequent commit is signed. It
+	// returns the metadata describing the signature that will be produced, and a cleanup function that removes any
+	// scratch state it created.
+	configure(ctx context.Context, dirPath string) (metadata *SignatureMetadata, cleanup func(), err error)
+}
+
+// newCommitSigner returns the commitSigner for the given config, or nil if signing is disabled.
+func newCommitSigner(cfg *SigningConfig) (commitSigner, error) {
+	if cfg == nil || cfg.Mode == SigningModeNone {
+		return nil, nil
+	}
+	switch cfg.Mode {
+	case SigningModeGPG:
+		if cfg.GPG == nil {
+			return nil, fmt.Errorf("signing mode %q requires gpg config", cfg.Mode)
+		}
+		return &gpgSigner{cfg: cfg.GPG}, nil
+	case SigningModeSSH:
+		if cfg.SSH == nil {
+			return nil, fmt.Errorf("signing mode %q requires ssh config", cfg.Mode)
+		}
+		return &sshSigner{cfg: cfg.SSH}, nil
+	default:
+		return nil, fmt.Errorf("unknown signing mode %q", cfg.Mode)
+	}
+}
+
+type gpgSigner struct {
+	cfg     *GPGSigningConfig
+	keyHome string
+}
+
+func (s *gpgSigner) configure(ctx context.Context, dirPath string) (*SignatureMetadata, func(), error) {
+	keyHome, err := os.MkdirTemp("", "commit-server-gnupghome-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create scratch GNUPGHOME: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(keyHome) }
+
+	importCmd := git.NewCommandContext(ctx, "", "gpg", "--batch", "--import")
+	importCmd.Env = append(os.Environ(), "GNUPGHOME="+keyHome)
+	importCmd.Stdin = bytes.NewReader(s.cfg.KeyRing)
+	if out, err := importCmd.CombinedOutput(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to import gpg key ring: %w: %s", err, out)
+	}
+	s.keyHome = keyHome
+
+	keyID := s.cfg.KeyID
+	if keyID == "" {
+		keyID, err = firstSecretKeyID(ctx, keyHome)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to determine gpg key id: %w", err)
+		}
+	}
+
+	// gpg needs to find the imported key ring when `git commit` runs it. git execs gpg.program directly, not through
+	// a shell, so GNUPGHOME is set by a small wrapper script kept inside keyHome.
+	gpgWrapper := filepath.Join(keyHome, "gpg-wrapper.sh")
+	wrapperScript := "#!/bin/sh\nGNUPGHOME='" + keyHome + "' exec gpg \"$@\"\n"
+	if err := os.WriteFile(gpgWrapper, []byte(wrapperScript), 0o700); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to write gpg wrapper script: %w", err)
+	}
+
+	for _, args := range [][]string{
+		{"commit.gpgsign", "true"},
+		{"gpg.format", "openpgp"},
+		{"user.signingkey", keyID},
+		{"gpg.program", gpgWrapper},
+	} {
+		if out, err := configureWorktreeGit(ctx, dirPath, args[0], args[1]); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to configure gpg signing: %w: %s", err, out)
+		}
+	}
+
+	return &SignatureMetadata{Mode: SigningModeGPG, Fingerprint: keyID}, cleanup, nil
+}
+
+type sshSigner struct {
+	cfg     *SSHSigningConfig
+	keyFile string
+}
+
+func (s *sshSigner) configure(ctx context.Context, dirPath string) (*SignatureMetadata, func(), error) {
+	keyFile, err := os.CreateTemp("", "commit-server-ssh-signing-key-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create scratch ssh key file: %w", err)
+	}
+	cleanup := func() { _ = os.Remove(keyFile.Name()) }
+
+	if _, err := keyFile.Write(s.cfg.PrivateKey); err != nil {
+		_ = keyFile.Close()
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to write scratch ssh key file: %w", err)
+	}
+	if err := keyFile.Close(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to close scratch ssh key file: %w", err)
+	}
+	if err := os.Chmod(keyFile.Name(), 0o600); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to chmod scratch ssh key file: %w", err)
+	}
+	s.keyFile = keyFile.Name()
+
+	for _, args := range [][]string{
+		{"commit.gpgsign", "true"},
+		{"gpg.format", "ssh"},
+		{"user.signingkey", keyFile.Name()},
+		{"gpg.ssh.allowedSignersFile", s.cfg.AllowedSignersFile},
+	} {
+		if out, err := configureWorktreeGit(ctx, dirPath, args[0], args[1]); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to configure ssh signing: %w: %s", err, out)
+		}
+	}
+
+	fingerprint, err := sshKeyFingerprint(ctx, s.keyFile)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to compute ssh key fingerprint: %w", err)
+	}
+
+	return &SignatureMetadata{Mode: SigningModeSSH, Fingerprint: fingerprint}, cleanup, nil
+}
+
+func sshKeyFingerprint(ctx context.Context, keyFile string) (string, error) {
+	out, err := git.NewCommandContext(ctx, "", "ssh-keygen", "-lf", keyFile).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run ssh-keygen: %w", err)
+	}
+	// ssh-keygen -l prints "<bits> <fingerprint> <comment> (<type>)"; keep only the fingerprint.
+	fields := strings.Fields(string(out))
+	if len(fields) < 2 {
+		return "", fmt.Errorf("unexpected ssh-keygen output: %q", out)
+	}
+	return fields[1], nil
+}
+
+// runGit runs a git subcommand in dirPath and returns its combined output, honoring ctx for cancellation and
+// deadlines. It exists because the git.Client used elsewhere in this package doesn't expose arbitrary subcommands,
+// and signing needs `git config` and `git log`.
+func runGit(ctx context.Context, dirPath string, args ...string) (string, error) {
+	return git.RunCommandContext(ctx, dirPath, "git", args...)
+}
+
+// configureWorktreeGit sets a git config key/value scoped to dirPath's linked worktree rather than the shared mirror
+// clone's config. dirPath is always a disposable per-request worktree (see workingCopyCache.PrepareWorktree), but
+// linked worktrees share their parent mirror's .git/config unless config is explicitly scoped with --worktree (which
+// itself requires extensions.worktreeConfig); without that, signing config written here would leak into the cached
+// mirror clone that every other request against this repo reuses. The extensions.worktreeConfig toggle is the one
+// value that has to live in the shared config, but it carries no secret material.
+func configureWorktreeGit(ctx context.Context, dirPath, key, value string) (string, error) {
+	if out, err := runGit(ctx, dirPath, "config", "extensions.worktreeConfig", "true"); err != nil {
+		return out, fmt.Errorf("failed to enable extensions.worktreeConfig: %w", err)
+	}
+	return runGit(ctx, dirPath, "config", "--worktree", key, value)
+}
+
+func firstSecretKeyID(ctx context.Context, gnupgHome string) (string, error) {
+	cmd := git.NewCommandContext(ctx, "", "gpg", "--batch", "--list-secret-keys", "--with-colons")
+	cmd.Env = append(os.Environ(), "GNUPGHOME="+gnupgHome)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to list secret keys: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		// In --with-colons output, an fpr record carries the fingerprint in field 10.
+		if len(fields) > 9 && fields[0] == "fpr" {
+			return fields[9], nil
+		}
+	}
+	return "", fmt.Errorf("no secret key found in key ring")
+}
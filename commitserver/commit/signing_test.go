@@ -0,0 +1,74 @@
+package commit
+
+import "testing"
+
+func TestNewCommitSigner_NilConfigDisablesSigning(t *testing.T) {
+	signer, err := newCommitSigner(nil)
+	if err != nil {
+		t.Fatalf("newCommitSigner(nil) returned error: %v", err)
+	}
+	if signer != nil {
+		t.Errorf("newCommitSigner(nil) = %v, want nil", signer)
+	}
+}
+
+func TestNewCommitSigner_ModeNoneDisablesSigning(t *testing.T) {
+	signer, err := newCommitSigner(&SigningConfig{Mode: SigningModeNone})
+	if err != nil {
+		t.Fatalf("newCommitSigner() returned error: %v", err)
+	}
+	if signer != nil {
+		t.Errorf("newCommitSigner() = %v, want nil", signer)
+	}
+}
+
+func TestNewCommitSigner_UnknownModeErrors(t *testing.T) {
+	_, err := newCommitSigner(&SigningConfig{Mode: "bogus"})
+	if err == nil {
+		t.Fatal("newCommitSigner() with an unknown mode did not return an error")
+	}
+}
+
+func TestNewCommitSigner_GPGModeRequiresGPGConfig(t *testing.T) {
+	_, err := newCommitSigner(&SigningConfig{Mode: SigningModeGPG})
+	if err == nil {
+		t.Fatal("newCommitSigner() with SigningModeGPG and nil GPG config did not return an error")
+	}
+}
+
+func TestNewCommitSigner_SSHModeRequiresSSHConfig(t *testing.T) {
+	_, err := newCommitSigner(&SigningConfig{Mode: SigningModeSSH})
+	if err == nil {
+		t.Fatal("newCommitSigner() with SigningModeSSH and nil SSH config did not return an error")
+	}
+}
+
+func TestNewCommitSigner_GPGModeReturnsGPGSigner(t *testing.T) {
+	cfg := &GPGSigningConfig{KeyID: "ABCD1234"}
+	signer, err := newCommitSigner(&SigningConfig{Mode: SigningModeGPG, GPG: cfg})
+	if err != nil {
+		t.Fatalf("newCommitSigner() returned error: %v", err)
+	}
+	gpg, ok := signer.(*gpgSigner)
+	if !ok {
+		t.Fatalf("newCommitSigner() = %T, want *gpgSigner", signer)
+	}
+	if gpg.cfg != cfg {
+		t.Error("gpgSigner.cfg does not point at the provided GPGSigningConfig")
+	}
+}
+
+func TestNewCommitSigner_SSHModeReturnsSSHSigner(t *testing.T) {
+	cfg := &SSHSigningConfig{AllowedSignersFile: "/allowed_signers"}
+	signer, err := newCommitSigner(&SigningConfig{Mode: SigningModeSSH, SSH: cfg})
+	if err != nil {
+		t.Fatalf("newCommitSigner() returned error: %v", err)
+	}
+	ssh, ok := signer.(*sshSigner)
+	if !ok {
+		t.Fatalf("newCommitSigner() = %T, want *sshSigner", signer)
+	}
+	if ssh.cfg != cfg {
+		t.Error("sshSigner.cfg does not point at the provided SSHSigningConfig")
+	}
+}
@@ -0,0 +1,218 @@
+package commit
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/argoproj/argo-cd/v2/util/git"
+)
+
+const (
+	defaultWorkingCopyCacheRoot = "/var/lib/argocd-commitserver/repos"
+	defaultMaxCachedRepos       = 100
+)
+
+// workingCopyCache keeps a persistent mirror clone per repo under root, keyed by sha256(repoURL), and hands out a
+// disposable `git worktree` per request for isolation. This avoids a full `git clone` on every request: once a
+// repo's mirror is cached, subsequent requests only need a `git fetch` plus a cheap worktree checkout.
+type workingCopyCache struct {
+	root     string
+	maxRepos int
+
+	mu          sync.Mutex // protects lru, lruElem, repoMutexes, and waiters
+	lru         *list.List // of repo keys, front = most recently used
+	lruElem     map[string]*list.Element
+	repoMutexes map[string]*sync.Mutex
+	// waiters counts, per key, how many LockRepo calls are currently either blocked acquiring repoMutexes[key] or
+	// holding it. A key with a nonzero count must never be evicted. This can't be a repoMu.TryLock() check in
+	// touchLocked: TryLock is allowed to barge ahead of a goroutine already blocked in Lock() on the same mutex, so
+	// eviction could "win" the lock out from under a waiter, delete the map entry, and unlock it - the waiter then
+	// wakes up holding a now-orphaned mutex while a fourth LockRepo call creates a brand new one for the same key,
+	// and both proceed concurrently against the same repoDir.
+	waiters map[string]int
+}
+
+// NewWorkingCopyCache returns a workingCopyCache rooted at root, retaining at most maxRepos mirror clones.
+func NewWorkingCopyCache(root string, maxRepos int) *workingCopyCache {
+	return &workingCopyCache{
+		root:        root,
+		maxRepos:    maxRepos,
+		lru:         list.New(),
+		lruElem:     map[string]*list.Element{},
+		repoMutexes: map[string]*sync.Mutex{},
+		waiters:     map[string]int{},
+	}
+}
+
+func (c *workingCopyCache) repoKey(repoURL string) string {
+	sum := sha256.Sum256([]byte(repoURL))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *workingCopyCache) repoDir(repoURL string) string {
+	return filepath.Join(c.root, c.repoKey(repoURL))
+}
+
+// LockRepo serializes concurrent commits to the same repo. The caller must hold the returned unlock function for
+// the entire request, including while using the worktree that PrepareWorktree hands back, and must call it exactly
+// once (typically via defer) when the request is done.
+//
+// LockRepo registers repoURL in the same LRU used for eviction, not just touch(), so a repo whose Init/Fetch then
+// fails in PrepareWorktree is still bounded by maxRepos: otherwise a stream of distinct unreachable/bad-creds repos
+// would leak a *sync.Mutex forever, since nothing else would ever add them to the LRU for touch() to evict.
+func (c *workingCopyCache) LockRepo(repoURL string) func() {
+	key := c.repoKey(repoURL)
+
+	c.mu.Lock()
+	repoMu, ok := c.repoMutexes[key]
+	if !ok {
+		repoMu = &sync.Mutex{}
+		c.repoMutexes[key] = repoMu
+	}
+	c.waiters[key]++
+	c.touchLocked(key)
+	c.mu.Unlock()
+
+	repoMu.Lock()
+
+	return func() {
+		repoMu.Unlock()
+
+		c.mu.Lock()
+		c.waiters[key]--
+		if c.waiters[key] == 0 {
+			delete(c.waiters, key)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// PrepareWorktree ensures a mirror clone of repoURL is present and up to date under the cache root, then creates a
+// fresh worktree off it for the caller's exclusive use. The caller must already hold the lock returned by LockRepo.
+// The returned cleanup function removes the worktree; the mirror clone itself stays cached for the next request.
+func (c *workingCopyCache) PrepareWorktree(ctx context.Context, logCtx *log.Entry, repoURL string, gitCreds git.Creds, insecure, lfsEnabled bool, proxy string, opts ...git.ClientOpts) (git.Client, string, func() error, error) {
+	repoDir := c.repoDir(repoURL)
+
+	mirrorClient, err := git.NewClientExt(repoURL, repoDir, gitCreds, insecure, lfsEnabled, proxy, opts...)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to create mirror git client: %w", err)
+	}
+
+	if _, err := os.Stat(repoDir); os.IsNotExist(err) {
+		logCtx.Debugf("No cached clone for %s, initializing mirror at %s", repoURL, repoDir)
+		if err := os.MkdirAll(repoDir, os.ModePerm); err != nil {
+			return nil, "", nil, fmt.Errorf("failed to create repo cache dir: %w", err)
+		}
+		if err := mirrorClient.Init(); err != nil {
+			return nil, "", nil, fmt.Errorf("failed to init mirror clone: %w", err)
+		}
+	}
+
+	logCtx.Debugf("Fetching into cached mirror clone %s", repoDir)
+	if err := mirrorClient.Fetch(ctx, ""); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to fetch mirror clone: %w", err)
+	}
+
+	c.touch(repoURL)
+	c.maintainGC(ctx, logCtx, repoDir)
+
+	worktreeDir, err := newWorktreeDir()
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to create worktree dir: %w", err)
+	}
+
+	// The mirror is built from `git init --bare` + `remote add origin` (see Client.Init), so everything fetched
+	// lands under refs/remotes/origin/* and the bare repo's own HEAD symref is never created; `git worktree add
+	// --detach <dir>` with no commit-ish resolves against that unborn HEAD and fails every time. FETCH_HEAD is
+	// always set by the Fetch call above and points at something valid, and which ref it happens to be doesn't
+	// matter: CheckoutOrOrphan/CheckoutOrNew immediately check out the real sync/target branch next.
+	logCtx.Debugf("Adding worktree %s", worktreeDir)
+	if out, err := runGit(ctx, repoDir, "worktree", "add", "--detach", worktreeDir, "FETCH_HEAD"); err != nil {
+		_ = os.RemoveAll(worktreeDir)
+		return nil, "", nil, fmt.Errorf("failed to add worktree: %w: %s", err, out)
+	}
+	cleanup := func() error {
+		if out, err := runGit(ctx, repoDir, "worktree", "remove", "--force", worktreeDir); err != nil {
+			return fmt.Errorf("failed to remove worktree: %w: %s", err, out)
+		}
+		return nil
+	}
+
+	worktreeClient, err := git.NewClientExt(repoURL, worktreeDir, gitCreds, insecure, lfsEnabled, proxy, opts...)
+	if err != nil {
+		_ = cleanup()
+		return nil, "", nil, fmt.Errorf("failed to create worktree git client: %w", err)
+	}
+	if err := worktreeClient.Init(); err != nil {
+		_ = cleanup()
+		return nil, "", nil, fmt.Errorf("failed to init worktree git client: %w", err)
+	}
+
+	return worktreeClient, worktreeDir, cleanup, nil
+}
+
+// touch marks repoURL as most-recently-used, evicting the least-recently-used cached repo if over maxRepos.
+// Eviction skips any repo with a nonzero waiters count, since that means a LockRepo call is currently blocked on or
+// holding its mutex.
+func (c *workingCopyCache) touch(repoURL string) {
+	key := c.repoKey(repoURL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.touchLocked(key)
+}
+
+// touchLocked is touch's key-keyed core, callable with c.mu already held (LockRepo needs to register a repo in the
+// same call where it creates the repo's mutex, while still holding c.mu).
+func (c *workingCopyCache) touchLocked(key string) {
+	if elem, ok := c.lruElem[key]; ok {
+		c.lru.MoveToFront(elem)
+	} else {
+		c.lruElem[key] = c.lru.PushFront(key)
+	}
+
+	for c.lru.Len() > c.maxRepos {
+		oldest := c.lru.Back()
+		oldestKey := oldest.Value.(string)
+		if oldestKey == key {
+			break // never evict the repo we just touched
+		}
+		if c.waiters[oldestKey] > 0 {
+			break // currently in use; try evicting it again next time
+		}
+		c.lru.Remove(oldest)
+		delete(c.lruElem, oldestKey)
+		delete(c.repoMutexes, oldestKey)
+		_ = os.RemoveAll(filepath.Join(c.root, oldestKey))
+	}
+}
+
+// maintainGC runs `git gc --auto`, which only does real work once enough loose objects have piled up, so it's cheap
+// to call after every fetch rather than running it on a separate timer.
+func (c *workingCopyCache) maintainGC(ctx context.Context, logCtx *log.Entry, repoDir string) {
+	if out, err := runGit(ctx, repoDir, "gc", "--auto"); err != nil {
+		logCtx.WithError(err).WithField("output", out).Warn("git gc --auto failed")
+	}
+}
+
+func newWorktreeDir() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate uuid: %w", err)
+	}
+	parent := filepath.Join(os.TempDir(), "_commit-service-worktrees")
+	if err := os.MkdirAll(parent, os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create worktree parent dir: %w", err)
+	}
+	return filepath.Join(parent, id.String()), nil
+}
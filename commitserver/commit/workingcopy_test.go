@@ -0,0 +1,242 @@
+package commit
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// noopCreds is a git.Creds that adds no extra environment and attributes commits to a fixed identity. PrepareWorktree
+// itself never calls GetUserInfo; noopCreds only gives it a Creds to hand to git.NewClientExt.
+type noopCreds struct{}
+
+func (noopCreds) Environ() ([]string, func(), error) { return nil, func() {}, nil }
+func (noopCreds) GetUserInfo(ctx context.Context) (string, string, error) {
+	return "Test", "test@example.com", nil
+}
+
+// runGitCmd runs a git subcommand in dir, failing the test on error. It's used to build a throwaway "remote" repo
+// for TestWorkingCopyCache_PrepareWorktree, independent of the package's own runGit/RunCommandContext helpers.
+func runGitCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(cmd.Environ(), "GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, out)
+	}
+}
+
+// TestWorkingCopyCache_PrepareWorktree guards against a regression where `git worktree add --detach <dir>` (with no
+// commit-ish) was run against the cached mirror clone. That mirror is built via `git init --bare` + `remote add
+// origin`, so everything lands under refs/remotes/origin/* and the bare repo's own HEAD is never created; worktree
+// add with no commit-ish resolves against that unborn HEAD and fails on every request.
+func TestWorkingCopyCache_PrepareWorktree(t *testing.T) {
+	remoteDir := t.TempDir()
+	runGitCmd(t, remoteDir, "init", "-q", "-b", "main")
+	runGitCmd(t, remoteDir, "commit", "-q", "--allow-empty", "-m", "init")
+
+	c := NewWorkingCopyCache(t.TempDir(), 10)
+	unlock := c.LockRepo(remoteDir)
+	defer unlock()
+
+	gitClient, worktreeDir, cleanup, err := c.PrepareWorktree(
+		context.Background(), log.NewEntry(log.StandardLogger()), remoteDir, noopCreds{}, false, false, "")
+	if err != nil {
+		t.Fatalf("PrepareWorktree() returned error: %v", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Errorf("cleanup() returned error: %v", err)
+		}
+	}()
+
+	if gitClient.Root() != worktreeDir {
+		t.Errorf("gitClient.Root() = %q, want %q", gitClient.Root(), worktreeDir)
+	}
+	if _, err := exec.Command("git", "-C", worktreeDir, "rev-parse", "HEAD").CombinedOutput(); err != nil {
+		t.Errorf("worktree at %s has no resolvable HEAD: %v", worktreeDir, err)
+	}
+}
+
+func TestWorkingCopyCache_RepoKeyIsStableAndDistinct(t *testing.T) {
+	c := NewWorkingCopyCache(t.TempDir(), 10)
+
+	if c.repoKey("https://example.com/a.git") != c.repoKey("https://example.com/a.git") {
+		t.Error("repoKey() is not stable for the same repo URL")
+	}
+	if c.repoKey("https://example.com/a.git") == c.repoKey("https://example.com/b.git") {
+		t.Error("repoKey() collided for two different repo URLs")
+	}
+}
+
+func TestWorkingCopyCache_TouchEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewWorkingCopyCache(t.TempDir(), 2)
+
+	c.touch("repo-a")
+	c.touch("repo-b")
+	c.touch("repo-c") // over the cap; repo-a is least recently used and should be evicted
+
+	if _, ok := c.lruElem[c.repoKey("repo-a")]; ok {
+		t.Error("touch() did not evict the least-recently-used repo")
+	}
+	if _, ok := c.lruElem[c.repoKey("repo-b")]; !ok {
+		t.Error("touch() evicted repo-b, which was more recently used than repo-a")
+	}
+	if _, ok := c.lruElem[c.repoKey("repo-c")]; !ok {
+		t.Error("touch() evicted repo-c, which was just touched")
+	}
+}
+
+func TestWorkingCopyCache_TouchReordersOnReuse(t *testing.T) {
+	c := NewWorkingCopyCache(t.TempDir(), 2)
+
+	c.touch("repo-a")
+	c.touch("repo-b")
+	c.touch("repo-a") // repo-a is now most-recently-used; repo-b is the LRU victim
+	c.touch("repo-c")
+
+	if _, ok := c.lruElem[c.repoKey("repo-b")]; ok {
+		t.Error("touch() did not evict repo-b after repo-a was re-touched")
+	}
+	if _, ok := c.lruElem[c.repoKey("repo-a")]; !ok {
+		t.Error("touch() evicted repo-a, which was re-touched more recently than repo-b")
+	}
+}
+
+// TestWorkingCopyCache_TouchSkipsRepoInUse guards against the eviction-while-in-use race: a repo whose mutex is
+// currently held (i.e. a request is actively using it) must not be evicted, even if it's the least recently used.
+func TestWorkingCopyCache_TouchSkipsRepoInUse(t *testing.T) {
+	c := NewWorkingCopyCache(t.TempDir(), 2)
+
+	c.touch("repo-a")
+	unlockA := c.LockRepo("repo-a")
+	c.touch("repo-b")
+	c.touch("repo-c") // would normally evict repo-a, but it's locked
+
+	if _, ok := c.lruElem[c.repoKey("repo-a")]; !ok {
+		t.Error("touch() evicted repo-a while its mutex was held")
+	}
+
+	unlockA()
+	c.touch("repo-c") // repo-a is no longer locked, so the next touch should evict it
+
+	if _, ok := c.lruElem[c.repoKey("repo-a")]; ok {
+		t.Error("touch() did not evict repo-a once it was no longer in use")
+	}
+}
+
+// TestWorkingCopyCache_TouchSkipsRepoWithBlockedWaiter guards against the eviction-while-blocked-waiter race: a repo
+// with a second LockRepo call blocked behind the current holder must not be evicted, even though its repoMu is
+// "available" to a bare TryLock (TryLock is documented to barge ahead of a goroutine already queued in Lock() on the
+// same mutex, so a TryLock-based eviction check could "win" the mutex out from under the blocked waiter).
+func TestWorkingCopyCache_TouchSkipsRepoWithBlockedWaiter(t *testing.T) {
+	c := NewWorkingCopyCache(t.TempDir(), 2)
+
+	unlockA := c.LockRepo("repo-a")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		unlock2 := c.LockRepo("repo-a") // blocks until unlockA() below
+		unlock2()
+	}()
+
+	// Give the second LockRepo call time to register its waiters++ and block on repoMu.Lock().
+	time.Sleep(50 * time.Millisecond)
+
+	if waiters := c.waiters[c.repoKey("repo-a")]; waiters != 2 {
+		t.Fatalf("waiters[repo-a] = %d, want 2 (the holder and the blocked waiter)", waiters)
+	}
+
+	c.touch("repo-b")
+	c.touch("repo-c") // would normally evict repo-a as the LRU victim, but it has a blocked waiter
+
+	if _, ok := c.lruElem[c.repoKey("repo-a")]; !ok {
+		t.Error("touch() evicted repo-a while a second LockRepo call was blocked on it")
+	}
+	if _, ok := c.repoMutexes[c.repoKey("repo-a")]; !ok {
+		t.Error("touch() removed repo-a's mutex while a second LockRepo call was blocked on it")
+	}
+
+	unlockA()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("blocked LockRepo() call never completed after the first lock was released")
+	}
+}
+
+// TestWorkingCopyCache_LockRepoBoundsUnfetchedRepos guards against a leak where a repo whose Init/Fetch later fails
+// (so touch() is never reached from PrepareWorktree) would otherwise never be registered in the LRU and could
+// never be evicted, letting repoMutexes grow without bound across many distinct failing repos.
+func TestWorkingCopyCache_LockRepoBoundsUnfetchedRepos(t *testing.T) {
+	c := NewWorkingCopyCache(t.TempDir(), 2)
+
+	for _, repo := range []string{"repo-a", "repo-b", "repo-c"} {
+		unlock := c.LockRepo(repo) // simulates a caller whose subsequent Init/Fetch fails and never calls touch()
+		unlock()
+	}
+
+	if len(c.repoMutexes) > 2 {
+		t.Errorf("repoMutexes grew to %d entries, want at most maxRepos (2); LockRepo() is leaking unfetched repos", len(c.repoMutexes))
+	}
+	if _, ok := c.lruElem[c.repoKey("repo-a")]; ok {
+		t.Error("LockRepo() did not register repo-a in the LRU, so it was never evicted")
+	}
+}
+
+func TestWorkingCopyCache_LockRepoSerializesSameRepo(t *testing.T) {
+	c := NewWorkingCopyCache(t.TempDir(), 10)
+
+	unlock := c.LockRepo("repo-a")
+
+	acquired := make(chan struct{})
+	go func() {
+		unlock2 := c.LockRepo("repo-a")
+		defer unlock2()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("LockRepo() allowed a second concurrent lock on the same repo")
+	case <-time.After(50 * time.Millisecond):
+		// expected: the second LockRepo call is still blocked
+	}
+
+	unlock()
+
+	select {
+	case <-acquired:
+		// expected: the second LockRepo call completed once the first was unlocked
+	case <-time.After(time.Second):
+		t.Fatal("LockRepo() never unblocked after the first lock was released")
+	}
+}
+
+func TestWorkingCopyCache_LockRepoDoesNotSerializeDifferentRepos(t *testing.T) {
+	c := NewWorkingCopyCache(t.TempDir(), 10)
+
+	unlockA := c.LockRepo("repo-a")
+	defer unlockA()
+
+	acquired := make(chan struct{})
+	go func() {
+		unlockB := c.LockRepo("repo-b")
+		defer unlockB()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		// expected: locking a different repo doesn't block on repo-a's lock
+	case <-time.After(time.Second):
+		t.Fatal("LockRepo() serialized two unrelated repos")
+	}
+}
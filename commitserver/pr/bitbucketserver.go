@@ -0,0 +1,137 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+)
+
+// BitbucketServerProvider opens or updates pull requests via the Bitbucket Server (Data Center) REST API,
+// authenticating with a personal access token from the TokenSource. Like the other providers, EnsurePullRequest only
+// ever changes title/description on an existing pull request, never its branches.
+type BitbucketServerProvider struct {
+	client  *restClient
+	metrics MetricsRecorder
+}
+
+// NewBitbucketServerProvider returns a Provider backed by the Bitbucket Server REST API at baseURL (e.g.
+// "https://bitbucket.example.com/rest/api/1.0").
+func NewBitbucketServerProvider(baseURL string, tokenSource TokenSource, metrics MetricsRecorder) *BitbucketServerProvider {
+	return &BitbucketServerProvider{
+		client: &restClient{
+			baseURL:     baseURL,
+			tokenSource: tokenSource,
+			authHeader:  tokenAuthHeader("Bearer"),
+		},
+		metrics: metrics,
+	}
+}
+
+type bitbucketPullRequest struct {
+	ID      int `json:"id"`
+	Version int `json:"version"`
+	FromRef struct {
+		DisplayID string `json:"displayId"`
+	} `json:"fromRef"`
+	ToRef struct {
+		DisplayID string `json:"displayId"`
+	} `json:"toRef"`
+	Links struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+type bitbucketPullRequestPage struct {
+	Values []bitbucketPullRequest `json:"values"`
+}
+
+// Repo for Bitbucket Server is "PROJECT/repo", which this provider splits back into the path segments the REST API
+// expects ({project}/repos/{repo}).
+func splitBitbucketRepo(repo string) (project, slug string, err error) {
+	for i, r := range repo {
+		if r == '/' {
+			return repo[:i], repo[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("bitbucket server repo %q must be in PROJECT/repo form", repo)
+}
+
+func (p *BitbucketServerProvider) EnsurePullRequest(ctx context.Context, req Request) (*Result, error) {
+	project, slug, err := splitBitbucketRepo(req.Repo)
+	if err != nil {
+		return nil, err
+	}
+	basePath := fmt.Sprintf("/projects/%s/repos/%s/pull-requests", project, slug)
+
+	existing, err := p.findOpenPullRequest(ctx, basePath, req)
+	if err != nil {
+		p.metrics.IncPullRequestFailed(string(ProviderBitbucketServer), req.Repo)
+		return nil, fmt.Errorf("failed to look up existing bitbucket server pull request: %w", err)
+	}
+
+	if existing != nil {
+		if err := p.client.do(ctx, "PUT", fmt.Sprintf("%s/%d", basePath, existing.ID), map[string]any{
+			"version":     existing.Version,
+			"title":       req.Title,
+			"description": req.Body,
+		}, nil); err != nil {
+			p.metrics.IncPullRequestFailed(string(ProviderBitbucketServer), req.Repo)
+			return nil, fmt.Errorf("failed to update bitbucket server pull request: %w", err)
+		}
+		if err := p.maybeAutoMerge(ctx, basePath, existing.ID, existing.Version, req.AutoMerge); err != nil {
+			return nil, err
+		}
+		p.metrics.IncPullRequestUpdated(string(ProviderBitbucketServer), req.Repo)
+		return &Result{ID: fmt.Sprint(existing.ID), URL: firstLink(*existing), Created: false}, nil
+	}
+
+	var created bitbucketPullRequest
+	if err := p.client.do(ctx, "POST", basePath, map[string]any{
+		"title":       req.Title,
+		"description": req.Body,
+		"fromRef":     map[string]string{"id": "refs/heads/" + req.Head},
+		"toRef":       map[string]string{"id": "refs/heads/" + req.Base},
+	}, &created); err != nil {
+		p.metrics.IncPullRequestFailed(string(ProviderBitbucketServer), req.Repo)
+		return nil, fmt.Errorf("failed to create bitbucket server pull request: %w", err)
+	}
+	if err := p.maybeAutoMerge(ctx, basePath, created.ID, created.Version, req.AutoMerge); err != nil {
+		return nil, err
+	}
+	p.metrics.IncPullRequestCreated(string(ProviderBitbucketServer), req.Repo)
+	return &Result{ID: fmt.Sprint(created.ID), URL: firstLink(created), Created: true}, nil
+}
+
+func (p *BitbucketServerProvider) findOpenPullRequest(ctx context.Context, basePath string, req Request) (*bitbucketPullRequest, error) {
+	var page bitbucketPullRequestPage
+	if err := p.client.do(ctx, "GET", basePath+"?state=OPEN", nil, &page); err != nil {
+		return nil, err
+	}
+	for _, candidate := range page.Values {
+		if candidate.FromRef.DisplayID == req.Head && candidate.ToRef.DisplayID == req.Base {
+			return &candidate, nil
+		}
+	}
+	return nil, nil
+}
+
+// maybeAutoMerge makes a single, immediate merge attempt; it does not wait or retry. See Request.AutoMerge and
+// GitHubProvider.maybeAutoMerge: Bitbucket Server has no server-side "merge once checks pass" mode, so on a repo
+// with required merge checks this attempt is expected to fail every time.
+func (p *BitbucketServerProvider) maybeAutoMerge(ctx context.Context, basePath string, id, version int, autoMerge bool) error {
+	if !autoMerge {
+		return nil
+	}
+	if err := p.client.do(ctx, "POST", fmt.Sprintf("%s/%d/merge?version=%d", basePath, id, version), nil, nil); err != nil {
+		return fmt.Errorf("failed to auto-merge bitbucket server pull request (may still be waiting on checks): %w", err)
+	}
+	return nil
+}
+
+func firstLink(pr bitbucketPullRequest) string {
+	if len(pr.Links.Self) == 0 {
+		return ""
+	}
+	return pr.Links.Self[0].Href
+}
@@ -0,0 +1,69 @@
+package pr
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// ProviderType selects which forge a Config targets.
+type ProviderType string
+
+const (
+	ProviderGitHub          ProviderType = "github"
+	ProviderGitLab          ProviderType = "gitlab"
+	ProviderGitea           ProviderType = "gitea"
+	ProviderBitbucketServer ProviderType = "bitbucket-server"
+)
+
+// Config configures pull/merge request creation for a commit.Service. It is attached via commit.WithPRProvider.
+type Config struct {
+	Provider ProviderType
+	// BaseURL is the API base URL for self-hosted instances (GitLab, Gitea, Bitbucket Server). Unused for github.com.
+	BaseURL string
+	// BaseBranch is the branch the pull/merge request merges into. If empty, commit.Service falls back to the
+	// request's sync branch.
+	BaseBranch string
+	// TitleTemplate and BodyTemplate are Go templates rendered against TemplateFields, the same way the hydrator
+	// README template is rendered against hydratorMetadataFile.
+	TitleTemplate string
+	BodyTemplate  string
+	// AutoMerge requests that the provider merge the pull/merge request automatically. Only the GitLab provider
+	// defers the merge until the pipeline succeeds; the GitHub, Gitea and Bitbucket Server providers make one
+	// immediate merge attempt and do not wait for checks (see Request.AutoMerge).
+	AutoMerge bool
+}
+
+// TemplateFields is the data made available to TitleTemplate and BodyTemplate.
+type TemplateFields struct {
+	RepoURL      string
+	DrySHA       string
+	TargetBranch string
+	BaseBranch   string
+	Commands     []string
+}
+
+// Render renders the configured title and body templates against fields.
+func (c Config) Render(fields TemplateFields) (title string, body string, err error) {
+	title, err = renderTemplate("title", c.TitleTemplate, fields)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render pull request title: %w", err)
+	}
+	body, err = renderTemplate("body", c.BodyTemplate, fields)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render pull request body: %w", err)
+	}
+	return title, body, nil
+}
+
+func renderTemplate(name, text string, fields TemplateFields) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, fields); err != nil {
+		return "", fmt.Errorf("failed to execute %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
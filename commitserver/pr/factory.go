@@ -0,0 +1,19 @@
+package pr
+
+import "fmt"
+
+// NewProvider returns the Provider for cfg.Provider.
+func NewProvider(cfg Config, tokenSource TokenSource, metrics MetricsRecorder) (Provider, error) {
+	switch cfg.Provider {
+	case ProviderGitHub:
+		return NewGitHubProvider(cfg.BaseURL, tokenSource, metrics), nil
+	case ProviderGitLab:
+		return NewGitLabProvider(cfg.BaseURL, tokenSource, metrics), nil
+	case ProviderGitea:
+		return NewGiteaProvider(cfg.BaseURL, tokenSource, metrics), nil
+	case ProviderBitbucketServer:
+		return NewBitbucketServerProvider(cfg.BaseURL, tokenSource, metrics), nil
+	default:
+		return nil, fmt.Errorf("unknown pull request provider %q", cfg.Provider)
+	}
+}
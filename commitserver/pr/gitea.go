@@ -0,0 +1,104 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+)
+
+// GiteaProvider opens or updates pull requests via the Gitea REST API, authenticating with a personal access token
+// from the TokenSource.
+type GiteaProvider struct {
+	client  *restClient
+	metrics MetricsRecorder
+}
+
+// NewGiteaProvider returns a Provider backed by the Gitea REST API at baseURL (e.g. "https://gitea.example.com/api/v1").
+func NewGiteaProvider(baseURL string, tokenSource TokenSource, metrics MetricsRecorder) *GiteaProvider {
+	return &GiteaProvider{
+		client: &restClient{
+			baseURL:     baseURL,
+			tokenSource: tokenSource,
+			authHeader:  tokenAuthHeader("token"),
+		},
+		metrics: metrics,
+	}
+}
+
+type giteaPull struct {
+	Number int    `json:"number"`
+	URL    string `json:"html_url"`
+	Head   struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+func (p *GiteaProvider) EnsurePullRequest(ctx context.Context, req Request) (*Result, error) {
+	existing, err := p.findOpenPull(ctx, req)
+	if err != nil {
+		p.metrics.IncPullRequestFailed(string(ProviderGitea), req.Repo)
+		return nil, fmt.Errorf("failed to look up existing gitea pull request: %w", err)
+	}
+
+	if existing != nil {
+		if err := p.client.do(ctx, "PATCH", fmt.Sprintf("/repos/%s/pulls/%d", req.Repo, existing.Number),
+			map[string]string{"title": req.Title, "body": req.Body}, nil); err != nil {
+			p.metrics.IncPullRequestFailed(string(ProviderGitea), req.Repo)
+			return nil, fmt.Errorf("failed to update gitea pull request: %w", err)
+		}
+		if err := p.maybeAutoMerge(ctx, req, existing.Number); err != nil {
+			return nil, err
+		}
+		p.metrics.IncPullRequestUpdated(string(ProviderGitea), req.Repo)
+		return &Result{ID: fmt.Sprint(existing.Number), URL: existing.URL, Created: false}, nil
+	}
+
+	var created giteaPull
+	if err := p.client.do(ctx, "POST", fmt.Sprintf("/repos/%s/pulls", req.Repo), map[string]string{
+		"title": req.Title,
+		"body":  req.Body,
+		"head":  req.Head,
+		"base":  req.Base,
+	}, &created); err != nil {
+		p.metrics.IncPullRequestFailed(string(ProviderGitea), req.Repo)
+		return nil, fmt.Errorf("failed to create gitea pull request: %w", err)
+	}
+	if err := p.maybeAutoMerge(ctx, req, created.Number); err != nil {
+		return nil, err
+	}
+	p.metrics.IncPullRequestCreated(string(ProviderGitea), req.Repo)
+	return &Result{ID: fmt.Sprint(created.Number), URL: created.URL, Created: true}, nil
+}
+
+// findOpenPull looks for an already-open pull request for req.Head -> req.Base. Unlike GitHub and GitLab, Gitea's
+// `GET /repos/{owner}/{repo}/pulls` doesn't support head/base query filters, so the only thing scoped server-side
+// is state=open; head and base are matched client-side against every open pull request for the repo.
+func (p *GiteaProvider) findOpenPull(ctx context.Context, req Request) (*giteaPull, error) {
+	var pulls []giteaPull
+	path := fmt.Sprintf("/repos/%s/pulls?state=open", req.Repo)
+	if err := p.client.do(ctx, "GET", path, nil, &pulls); err != nil {
+		return nil, err
+	}
+	for _, pull := range pulls {
+		if pull.Head.Ref == req.Head && pull.Base.Ref == req.Base {
+			return &pull, nil
+		}
+	}
+	return nil, nil
+}
+
+// maybeAutoMerge makes a single, immediate merge attempt; it does not wait or retry. See Request.AutoMerge and
+// GitHubProvider.maybeAutoMerge: Gitea has no server-side "merge once checks pass" mode, so on a repo with required
+// status checks this attempt is expected to fail every time.
+func (p *GiteaProvider) maybeAutoMerge(ctx context.Context, req Request, index int) error {
+	if !req.AutoMerge {
+		return nil
+	}
+	if err := p.client.do(ctx, "POST", fmt.Sprintf("/repos/%s/pulls/%d/merge", req.Repo, index),
+		map[string]string{"Do": "squash"}, nil); err != nil {
+		return fmt.Errorf("failed to auto-merge gitea pull request (may still be waiting on checks): %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const githubAPIBaseURL = "https://api.github.com"
+
+// GitHubProvider opens or updates pull requests via the GitHub REST API, authenticating with a GitHub App
+// installation token (or a PAT) from the TokenSource.
+type GitHubProvider struct {
+	client  *restClient
+	metrics MetricsRecorder
+}
+
+// NewGitHubProvider returns a Provider backed by the GitHub REST API. baseURL should be left empty for github.com,
+// and set to a GitHub Enterprise Server's API base URL otherwise.
+func NewGitHubProvider(baseURL string, tokenSource TokenSource, metrics MetricsRecorder) *GitHubProvider {
+	if baseURL == "" {
+		baseURL = githubAPIBaseURL
+	}
+	return &GitHubProvider{
+		client: &restClient{
+			baseURL:     baseURL,
+			tokenSource: tokenSource,
+			authHeader:  tokenAuthHeader("token"),
+		},
+		metrics: metrics,
+	}
+}
+
+type githubPull struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	State   string `json:"state"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+}
+
+func (p *GitHubProvider) EnsurePullRequest(ctx context.Context, req Request) (*Result, error) {
+	existing, err := p.findOpenPull(ctx, req)
+	if err != nil {
+		p.metrics.IncPullRequestFailed(string(ProviderGitHub), req.Repo)
+		return nil, fmt.Errorf("failed to look up existing github pull request: %w", err)
+	}
+
+	if existing != nil {
+		if err := p.client.do(ctx, "PATCH", fmt.Sprintf("/repos/%s/pulls/%d", req.Repo, existing.Number),
+			map[string]string{"title": req.Title, "body": req.Body}, nil); err != nil {
+			p.metrics.IncPullRequestFailed(string(ProviderGitHub), req.Repo)
+			return nil, fmt.Errorf("failed to update github pull request: %w", err)
+		}
+		if err := p.maybeAutoMerge(ctx, req, existing.Number); err != nil {
+			return nil, err
+		}
+		p.metrics.IncPullRequestUpdated(string(ProviderGitHub), req.Repo)
+		return &Result{ID: fmt.Sprint(existing.Number), URL: existing.HTMLURL, Created: false}, nil
+	}
+
+	var created githubPull
+	if err := p.client.do(ctx, "POST", fmt.Sprintf("/repos/%s/pulls", req.Repo), map[string]string{
+		"title": req.Title,
+		"body":  req.Body,
+		"head":  req.Head,
+		"base":  req.Base,
+	}, &created); err != nil {
+		p.metrics.IncPullRequestFailed(string(ProviderGitHub), req.Repo)
+		return nil, fmt.Errorf("failed to create github pull request: %w", err)
+	}
+	if err := p.maybeAutoMerge(ctx, req, created.Number); err != nil {
+		return nil, err
+	}
+	p.metrics.IncPullRequestCreated(string(ProviderGitHub), req.Repo)
+	return &Result{ID: fmt.Sprint(created.Number), URL: created.HTMLURL, Created: true}, nil
+}
+
+func (p *GitHubProvider) findOpenPull(ctx context.Context, req Request) (*githubPull, error) {
+	owner, _, ok := strings.Cut(req.Repo, "/")
+	if !ok {
+		return nil, fmt.Errorf("github repo %q must be in owner/repo form", req.Repo)
+	}
+
+	var pulls []githubPull
+	path := fmt.Sprintf("/repos/%s/pulls?state=open&head=%s&base=%s",
+		req.Repo, url.QueryEscape(owner+":"+req.Head), url.QueryEscape(req.Base))
+	if err := p.client.do(ctx, "GET", path, nil, &pulls); err != nil {
+		return nil, err
+	}
+	for _, pull := range pulls {
+		if pull.Head.Ref == req.Head {
+			return &pull, nil
+		}
+	}
+	return nil, nil
+}
+
+// maybeAutoMerge makes a single, immediate merge attempt; it does not wait or retry. Unlike GitLab's
+// merge_when_pipeline_succeeds, GitHub has no "merge once checks pass" mode to hand this off to, so on any repo
+// with required status checks that haven't run yet (which is the common case: this is called right after the
+// commit that would trigger them was just pushed), the merge is rejected and AutoMerge has no effect for this pull
+// request. See Request.AutoMerge.
+func (p *GitHubProvider) maybeAutoMerge(ctx context.Context, req Request, number int) error {
+	if !req.AutoMerge {
+		return nil
+	}
+	if err := p.client.do(ctx, "PUT", fmt.Sprintf("/repos/%s/pulls/%d/merge", req.Repo, number),
+		map[string]string{"merge_method": "squash"}, nil); err != nil {
+		// Checks may not have passed yet; GitHub will reject the merge until they do. That's expected, so this is
+		// logged by the caller rather than treated as a hard failure here.
+		return fmt.Errorf("failed to auto-merge github pull request (may still be waiting on checks): %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+const gitlabAPIBaseURL = "https://gitlab.com/api/v4"
+
+// GitLabProvider opens or updates merge requests via the GitLab REST API, authenticating with a project or personal
+// access token from the TokenSource.
+type GitLabProvider struct {
+	client  *restClient
+	metrics MetricsRecorder
+}
+
+// NewGitLabProvider returns a Provider backed by the GitLab REST API. baseURL should be left empty for gitlab.com,
+// and set to a self-managed instance's API base URL otherwise.
+func NewGitLabProvider(baseURL string, tokenSource TokenSource, metrics MetricsRecorder) *GitLabProvider {
+	if baseURL == "" {
+		baseURL = gitlabAPIBaseURL
+	}
+	return &GitLabProvider{
+		client: &restClient{
+			baseURL:     baseURL,
+			tokenSource: tokenSource,
+			authHeader:  tokenAuthHeader("Bearer"),
+		},
+		metrics: metrics,
+	}
+}
+
+type gitlabMergeRequest struct {
+	IID          int    `json:"iid"`
+	WebURL       string `json:"web_url"`
+	SourceBranch string `json:"source_branch"`
+}
+
+func (p *GitLabProvider) EnsurePullRequest(ctx context.Context, req Request) (*Result, error) {
+	project := url.PathEscape(req.Repo)
+
+	existing, err := p.findOpenMergeRequest(ctx, project, req)
+	if err != nil {
+		p.metrics.IncPullRequestFailed(string(ProviderGitLab), req.Repo)
+		return nil, fmt.Errorf("failed to look up existing gitlab merge request: %w", err)
+	}
+
+	if existing != nil {
+		if err := p.client.do(ctx, "PUT", fmt.Sprintf("/projects/%s/merge_requests/%d", project, existing.IID),
+			map[string]string{"title": req.Title, "description": req.Body}, nil); err != nil {
+			p.metrics.IncPullRequestFailed(string(ProviderGitLab), req.Repo)
+			return nil, fmt.Errorf("failed to update gitlab merge request: %w", err)
+		}
+		if err := p.maybeAutoMerge(ctx, project, existing.IID, req.AutoMerge); err != nil {
+			return nil, err
+		}
+		p.metrics.IncPullRequestUpdated(string(ProviderGitLab), req.Repo)
+		return &Result{ID: fmt.Sprint(existing.IID), URL: existing.WebURL, Created: false}, nil
+	}
+
+	var created gitlabMergeRequest
+	if err := p.client.do(ctx, "POST", fmt.Sprintf("/projects/%s/merge_requests", project), map[string]string{
+		"title":         req.Title,
+		"description":   req.Body,
+		"source_branch": req.Head,
+		"target_branch": req.Base,
+	}, &created); err != nil {
+		p.metrics.IncPullRequestFailed(string(ProviderGitLab), req.Repo)
+		return nil, fmt.Errorf("failed to create gitlab merge request: %w", err)
+	}
+	if err := p.maybeAutoMerge(ctx, project, created.IID, req.AutoMerge); err != nil {
+		return nil, err
+	}
+	p.metrics.IncPullRequestCreated(string(ProviderGitLab), req.Repo)
+	return &Result{ID: fmt.Sprint(created.IID), URL: created.WebURL, Created: true}, nil
+}
+
+func (p *GitLabProvider) findOpenMergeRequest(ctx context.Context, project string, req Request) (*gitlabMergeRequest, error) {
+	var mrs []gitlabMergeRequest
+	path := fmt.Sprintf("/projects/%s/merge_requests?state=opened&source_branch=%s&target_branch=%s",
+		project, url.QueryEscape(req.Head), url.QueryEscape(req.Base))
+	if err := p.client.do(ctx, "GET", path, nil, &mrs); err != nil {
+		return nil, err
+	}
+	for _, mr := range mrs {
+		if mr.SourceBranch == req.Head {
+			return &mr, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *GitLabProvider) maybeAutoMerge(ctx context.Context, project string, iid int, autoMerge bool) error {
+	if !autoMerge {
+		return nil
+	}
+	if err := p.client.do(ctx, "PUT", fmt.Sprintf("/projects/%s/merge_requests/%d/merge", project, iid),
+		map[string]bool{"merge_when_pipeline_succeeds": true}, nil); err != nil {
+		return fmt.Errorf("failed to set gitlab merge request to merge when pipeline succeeds: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Package pr opens (or updates) a pull/merge request after the commit server pushes hydrated manifests to a target
+// branch. It is deliberately decoupled from commitserver/commit: Provider only needs a Request and the credentials
+// already resolved via git.CredsStore, so it can be unit tested without a real git working copy.
+package pr
+
+import "context"
+
+// Provider opens or updates a pull/merge request on a specific forge (GitHub, GitLab, Gitea, Bitbucket Server).
+type Provider interface {
+	// EnsurePullRequest opens a pull/merge request for req.Head -> req.Base, or updates the existing one for that
+	// branch pair if one is already open. It must be idempotent: calling it twice for the same branch pair should
+	// not create a second pull request.
+	EnsurePullRequest(ctx context.Context, req Request) (*Result, error)
+}
+
+// Request describes the pull/merge request to open or update.
+type Request struct {
+	// Repo is the forge-specific repository identifier (e.g. "owner/repo" for GitHub, GitLab, Gitea; "PROJECT/repo"
+	// for Bitbucket Server).
+	Repo string
+	// Head is the branch containing the hydrated manifests (commit.Service's TargetBranch).
+	Head string
+	// Base is the branch the pull/merge request should merge into.
+	Base string
+	// Title is the rendered pull/merge request title.
+	Title string
+	// Body is the rendered pull/merge request body, typically Config.BodyTemplate rendered against TemplateFields.
+	Body string
+	// AutoMerge requests that the provider merge the pull/merge request automatically once checks pass, if the
+	// forge supports it. Only GitLabProvider actually waits: it sets merge_when_pipeline_succeeds, which GitLab
+	// evaluates server-side as the pipeline progresses. GitHubProvider, GiteaProvider, and BitbucketServerProvider
+	// instead make a single synchronous merge attempt right after EnsurePullRequest opens or updates the pull
+	// request (which commit.Service calls right after pushing the hydrated commit), with no polling or retry; on any repo with required status checks that haven't run yet, that attempt will
+	// deterministically fail every time. Treat AutoMerge as GitLab-only until those three gain a retry loop.
+	AutoMerge bool
+}
+
+// Result is what EnsurePullRequest produced.
+type Result struct {
+	// ID is the provider-specific pull/merge request identifier (e.g. PR number).
+	ID string
+	// URL links to the pull/merge request in the forge's UI.
+	URL string
+	// Created is true if a new pull/merge request was opened, false if an existing one was updated.
+	Created bool
+}
+
+// MetricsRecorder records outcomes of pull/merge request operations, labeled by provider and repo. It is a small
+// interface (rather than a direct dependency on commitserver/metrics) so providers can be unit tested without a
+// metrics server.
+type MetricsRecorder interface {
+	IncPullRequestCreated(provider, repo string)
+	IncPullRequestUpdated(provider, repo string)
+	IncPullRequestFailed(provider, repo string)
+}
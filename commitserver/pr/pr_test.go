@@ -0,0 +1,160 @@
+package pr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func staticTokenSource(token string) TokenSource {
+	return func(ctx context.Context) (string, error) { return token, nil }
+}
+
+func serveJSON(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGitHubProvider_FindOpenPullMatchesHeadRef(t *testing.T) {
+	srv := serveJSON(t, `[
+		{"number": 1, "html_url": "https://example.com/1", "state": "open", "head": {"ref": "other-branch"}},
+		{"number": 2, "html_url": "https://example.com/2", "state": "open", "head": {"ref": "feature"}}
+	]`)
+	p := NewGitHubProvider(srv.URL, staticTokenSource("t"), nil)
+
+	pull, err := p.findOpenPull(context.Background(), Request{Repo: "owner/repo", Head: "feature", Base: "main"})
+	if err != nil {
+		t.Fatalf("findOpenPull() returned error: %v", err)
+	}
+	if pull == nil || pull.Number != 2 {
+		t.Fatalf("findOpenPull() = %+v, want the pull request with head ref %q", pull, "feature")
+	}
+}
+
+func TestGitHubProvider_FindOpenPullNoMatch(t *testing.T) {
+	srv := serveJSON(t, `[{"number": 1, "html_url": "https://example.com/1", "state": "open", "head": {"ref": "other-branch"}}]`)
+	p := NewGitHubProvider(srv.URL, staticTokenSource("t"), nil)
+
+	pull, err := p.findOpenPull(context.Background(), Request{Repo: "owner/repo", Head: "feature", Base: "main"})
+	if err != nil {
+		t.Fatalf("findOpenPull() returned error: %v", err)
+	}
+	if pull != nil {
+		t.Fatalf("findOpenPull() = %+v, want nil when no pull request matches head ref", pull)
+	}
+}
+
+func TestGitHubProvider_FindOpenPullRejectsRepoWithoutOwner(t *testing.T) {
+	p := NewGitHubProvider("", staticTokenSource("t"), nil)
+
+	if _, err := p.findOpenPull(context.Background(), Request{Repo: "repo-without-owner"}); err == nil {
+		t.Fatal("findOpenPull() did not return an error for a repo not in owner/repo form")
+	}
+}
+
+func TestGitLabProvider_FindOpenMergeRequestMatchesSourceBranch(t *testing.T) {
+	srv := serveJSON(t, `[
+		{"iid": 1, "web_url": "https://example.com/1", "source_branch": "other-branch"},
+		{"iid": 2, "web_url": "https://example.com/2", "source_branch": "feature"}
+	]`)
+	p := NewGitLabProvider(srv.URL, staticTokenSource("t"), nil)
+
+	mr, err := p.findOpenMergeRequest(context.Background(), "owner%2Frepo", Request{Head: "feature", Base: "main"})
+	if err != nil {
+		t.Fatalf("findOpenMergeRequest() returned error: %v", err)
+	}
+	if mr == nil || mr.IID != 2 {
+		t.Fatalf("findOpenMergeRequest() = %+v, want the merge request with source branch %q", mr, "feature")
+	}
+}
+
+func TestGitLabProvider_FindOpenMergeRequestNoMatch(t *testing.T) {
+	srv := serveJSON(t, `[{"iid": 1, "web_url": "https://example.com/1", "source_branch": "other-branch"}]`)
+	p := NewGitLabProvider(srv.URL, staticTokenSource("t"), nil)
+
+	mr, err := p.findOpenMergeRequest(context.Background(), "owner%2Frepo", Request{Head: "feature", Base: "main"})
+	if err != nil {
+		t.Fatalf("findOpenMergeRequest() returned error: %v", err)
+	}
+	if mr != nil {
+		t.Fatalf("findOpenMergeRequest() = %+v, want nil when no merge request matches source branch", mr)
+	}
+}
+
+func TestGiteaProvider_FindOpenPullMatchesHeadAndBase(t *testing.T) {
+	srv := serveJSON(t, `[
+		{"number": 1, "html_url": "https://example.com/1", "head": {"ref": "feature"}, "base": {"ref": "other-base"}},
+		{"number": 2, "html_url": "https://example.com/2", "head": {"ref": "feature"}, "base": {"ref": "main"}}
+	]`)
+	p := NewGiteaProvider(srv.URL, staticTokenSource("t"), nil)
+
+	pull, err := p.findOpenPull(context.Background(), Request{Repo: "owner/repo", Head: "feature", Base: "main"})
+	if err != nil {
+		t.Fatalf("findOpenPull() returned error: %v", err)
+	}
+	if pull == nil || pull.Number != 2 {
+		t.Fatalf("findOpenPull() = %+v, want the pull request matching both head and base", pull)
+	}
+}
+
+func TestGiteaProvider_FindOpenPullRequiresBaseMatchNotJustHead(t *testing.T) {
+	srv := serveJSON(t, `[{"number": 1, "html_url": "https://example.com/1", "head": {"ref": "feature"}, "base": {"ref": "other-base"}}]`)
+	p := NewGiteaProvider(srv.URL, staticTokenSource("t"), nil)
+
+	pull, err := p.findOpenPull(context.Background(), Request{Repo: "owner/repo", Head: "feature", Base: "main"})
+	if err != nil {
+		t.Fatalf("findOpenPull() returned error: %v", err)
+	}
+	if pull != nil {
+		t.Fatalf("findOpenPull() = %+v, want nil: head ref matches but base ref does not", pull)
+	}
+}
+
+func TestBitbucketServerProvider_FindOpenPullRequestMatchesFromRef(t *testing.T) {
+	srv := serveJSON(t, `{"values": [
+		{"id": 1, "version": 0, "fromRef": {"displayId": "other-branch"}, "links": {"self": [{"href": "https://example.com/1"}]}},
+		{"id": 2, "version": 3, "fromRef": {"displayId": "feature"}, "links": {"self": [{"href": "https://example.com/2"}]}}
+	]}`)
+	p := NewBitbucketServerProvider(srv.URL, staticTokenSource("t"), nil)
+
+	pr, err := p.findOpenPullRequest(context.Background(), "/projects/PROJ/repos/repo/pull-requests", Request{Head: "feature"})
+	if err != nil {
+		t.Fatalf("findOpenPullRequest() returned error: %v", err)
+	}
+	if pr == nil || pr.ID != 2 {
+		t.Fatalf("findOpenPullRequest() = %+v, want the pull request with fromRef.displayId %q", pr, "feature")
+	}
+}
+
+func TestBitbucketServerProvider_FindOpenPullRequestNoMatch(t *testing.T) {
+	srv := serveJSON(t, `{"values": [{"id": 1, "version": 0, "fromRef": {"displayId": "other-branch"}}]}`)
+	p := NewBitbucketServerProvider(srv.URL, staticTokenSource("t"), nil)
+
+	pr, err := p.findOpenPullRequest(context.Background(), "/projects/PROJ/repos/repo/pull-requests", Request{Head: "feature"})
+	if err != nil {
+		t.Fatalf("findOpenPullRequest() returned error: %v", err)
+	}
+	if pr != nil {
+		t.Fatalf("findOpenPullRequest() = %+v, want nil when no pull request matches fromRef", pr)
+	}
+}
+
+func TestSplitBitbucketRepo(t *testing.T) {
+	project, slug, err := splitBitbucketRepo("PROJ/repo")
+	if err != nil {
+		t.Fatalf("splitBitbucketRepo() returned error: %v", err)
+	}
+	if project != "PROJ" || slug != "repo" {
+		t.Fatalf("splitBitbucketRepo() = (%q, %q), want (%q, %q)", project, slug, "PROJ", "repo")
+	}
+
+	if _, _, err := splitBitbucketRepo("no-slash"); err == nil {
+		t.Fatal("splitBitbucketRepo() did not return an error for a repo not in PROJECT/repo form")
+	}
+}
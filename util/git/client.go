@@ -0,0 +1,206 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Creds resolves the credentials (and any auxiliary environment) a Client needs to authenticate against a remote.
+type Creds interface {
+	// Environ returns extra environment variables (e.g. GIT_ASKPASS, SSH credentials) to set on every git subprocess
+	// this Client runs, and a cleanup function that must be called once the Client is done with them (e.g. to remove
+	// a scratch askpass script).
+	Environ() ([]string, func(), error)
+	// GetUserInfo returns the author name and email to use for commits produced against this remote (e.g. a GitHub
+	// App's bot identity).
+	GetUserInfo(ctx context.Context) (string, string, error)
+}
+
+// CredsStore resolves username/password credentials for a repo URL. It's the interface NetrcCookieFallbackStore
+// wraps to add .netrc/git-cookies fallback lookups.
+type CredsStore interface {
+	GetCreds(repoURL string) (string, string, error)
+}
+
+// EventHandlers lets callers observe the git subprocesses a Client runs, without the git package depending on a
+// metrics implementation. Any field left nil is simply not called.
+type EventHandlers struct {
+	// OnGitCommandExecuted is called after every git subprocess the Client runs, labeled by the subcommand name (e.g.
+	// "fetch", "commit").
+	OnGitCommandExecuted func(repoURL, subcommand string, duration time.Duration, err error)
+}
+
+// ClientOpts configures optional behavior on a Client, applied by NewClientExt in the order given.
+type ClientOpts func(*nativeGitClient)
+
+// WithEventHandlers configures a Client to report every git subprocess it runs to handlers.
+func WithEventHandlers(handlers EventHandlers) ClientOpts {
+	return func(c *nativeGitClient) {
+		c.eventHandlers = handlers
+	}
+}
+
+// Client operates on a single git working copy rooted at Root(), authenticating as Creds. Fetch, CheckoutOrOrphan,
+// CheckoutOrNew, RemoveContents and CommitAndPush take a context.Context and honor its deadline/cancellation via
+// NewCommandContext, so a caller can bound how long it's willing to wait on a single remote (see
+// commitserver/commit, which wraps those calls in a per-repo timeout). Init and SetAuthor take no context and run
+// without a deadline.
+type Client interface {
+	// Root returns the working copy's root directory.
+	Root() string
+	// Init runs `git init --bare` in Root(), which must already exist, and adds the repo URL as the origin remote,
+	// unless Root() already contains a .git. It clones/fetches nothing.
+	Init() error
+	// Fetch fetches revision (or, if empty, the remote's default refspecs) from the configured remote.
+	Fetch(ctx context.Context, revision string) error
+	// SetAuthor sets the user.name and user.email that subsequent commits in this working copy are attributed to.
+	SetAuthor(name, email string) (string, error)
+	// CheckoutOrOrphan checks out branch, creating it as an orphan (no parent history) if it doesn't exist on the
+	// remote. If force is true, any local changes are discarded rather than blocking the checkout.
+	CheckoutOrOrphan(ctx context.Context, branch string, force bool) (string, error)
+	// CheckoutOrNew checks out branch, creating it from base if it doesn't exist on the remote.
+	CheckoutOrNew(ctx context.Context, branch, base string, force bool) (string, error)
+	// RemoveContents removes every tracked file from the working copy (`git rm -rf .`), leaving .git intact.
+	RemoveContents(ctx context.Context) (string, error)
+	// CommitAndPush stages every change in the working copy (`git add --all`), commits it with message and pushes
+	// branch to the remote.
+	CommitAndPush(ctx context.Context, branch, message string) (string, error)
+}
+
+// nativeGitClient implements Client by shelling out to the system git binary.
+type nativeGitClient struct {
+	repoURL       string
+	root          string
+	creds         Creds
+	insecure      bool
+	lfsEnabled    bool
+	proxy         string
+	eventHandlers EventHandlers
+}
+
+// NewClientExt returns a Client for repoURL, rooted at dir. dir is not created here, and Init expects it to already
+// exist; callers that want a clone or a fetch must call Fetch themselves.
+func NewClientExt(repoURL, dir string, creds Creds, insecure, lfsEnabled bool, proxy string, opts ...ClientOpts) (Client, error) {
+	c := &nativeGitClient{
+		repoURL:    repoURL,
+		root:       dir,
+		creds:      creds,
+		insecure:   insecure,
+		lfsEnabled: lfsEnabled,
+		proxy:      proxy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+func (c *nativeGitClient) Root() string {
+	return c.root
+}
+
+func (c *nativeGitClient) Init() error {
+	if _, err := os.Stat(filepath.Join(c.root, ".git")); err == nil {
+		return nil
+	}
+	if _, err := c.run(context.Background(), "init", "--bare"); err != nil {
+		return fmt.Errorf("failed to init repo: %w", err)
+	}
+	if _, err := c.run(context.Background(), "remote", "add", "origin", c.repoURL); err != nil {
+		return fmt.Errorf("failed to add origin remote: %w", err)
+	}
+	return nil
+}
+
+func (c *nativeGitClient) Fetch(ctx context.Context, revision string) error {
+	args := []string{"fetch", "origin", "--tags", "--force"}
+	if revision != "" {
+		args = append(args, revision)
+	}
+	if _, err := c.run(ctx, args...); err != nil {
+		return fmt.Errorf("failed to fetch: %w", err)
+	}
+	return nil
+}
+
+func (c *nativeGitClient) SetAuthor(name, email string) (string, error) {
+	out, err := c.run(context.Background(), "config", "user.name", name)
+	if err != nil {
+		return out, err
+	}
+	return c.run(context.Background(), "config", "user.email", email)
+}
+
+func (c *nativeGitClient) CheckoutOrOrphan(ctx context.Context, branch string, force bool) (string, error) {
+	checkoutArgs := []string{"checkout", branch}
+	if force {
+		checkoutArgs = append(checkoutArgs, "--force")
+	}
+	if out, err := c.run(ctx, checkoutArgs...); err == nil {
+		return out, nil
+	}
+	args := []string{"checkout", "--orphan", branch}
+	if force {
+		args = append(args, "--force")
+	}
+	return c.run(ctx, args...)
+}
+
+func (c *nativeGitClient) CheckoutOrNew(ctx context.Context, branch, base string, force bool) (string, error) {
+	if out, err := c.run(ctx, "checkout", branch); err == nil {
+		return out, nil
+	}
+	args := []string{"checkout", "-b", branch, base}
+	if force {
+		args = append(args, "--force")
+	}
+	return c.run(ctx, args...)
+}
+
+func (c *nativeGitClient) RemoveContents(ctx context.Context) (string, error) {
+	return c.run(ctx, "rm", "-rf", "--ignore-unmatch", ".")
+}
+
+func (c *nativeGitClient) CommitAndPush(ctx context.Context, branch, message string) (string, error) {
+	if out, err := c.run(ctx, "add", "--all"); err != nil {
+		return out, fmt.Errorf("failed to stage changes: %w", err)
+	}
+	out, err := c.run(ctx, "commit", "--message", message)
+	if err != nil {
+		return out, fmt.Errorf("failed to commit: %w", err)
+	}
+	pushOut, err := c.run(ctx, "push", "origin", "HEAD:refs/heads/"+branch)
+	return out + pushOut, err
+}
+
+// run runs a git subcommand against this client's working copy, honoring ctx for cancellation/deadlines via
+// NewCommandContext, and reports it to eventHandlers.OnGitCommandExecuted.
+func (c *nativeGitClient) run(ctx context.Context, args ...string) (string, error) {
+	var env []string
+	if c.creds != nil {
+		credsEnv, closer, err := c.creds.Environ()
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve credentials: %w", err)
+		}
+		defer closer()
+		env = credsEnv
+	}
+
+	start := time.Now()
+	cmd := NewCommandContext(ctx, c.root, "git", args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %w: %s", ErrCommandTimedOut, ctx.Err(), out)
+	}
+	if handler := c.eventHandlers.OnGitCommandExecuted; handler != nil {
+		handler(c.repoURL, args[0], time.Since(start), err)
+	}
+	return string(out), err
+}
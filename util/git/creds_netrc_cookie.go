@@ -0,0 +1,200 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cookieFileLookupTimeout bounds the `git config --get http.cookiefile` subprocess lookupCookieFile shells out to.
+// GetCreds has no caller-supplied context.Context to honor (CredsStore predates the context-aware Client), so this
+// keeps it from hanging indefinitely the way every other git subprocess in this package is bounded.
+const cookieFileLookupTimeout = 10 * time.Second
+
+// NetrcCookieFallbackStore wraps an existing CredsStore and, for any host it has no credentials for, falls back to
+// reading ~/.netrc and the file referenced by `git config --get http.cookiefile`. This lets operators mount
+// existing Gerrit/GitHub Enterprise credential files into the commit-server pod without materializing them as
+// ArgoCD repository secrets.
+type NetrcCookieFallbackStore struct {
+	CredsStore
+	// netrcPath overrides where .netrc is read from. If empty, $HOME/.netrc is used.
+	netrcPath string
+	// cookieFilePath overrides where the git cookie file is read from. If empty, it's resolved by running
+	// `git config --get http.cookiefile`.
+	cookieFilePath string
+}
+
+// NewNetrcCookieFallbackStore wraps store with .netrc/cookie-file fallback lookups. Pass empty strings for
+// netrcPath and cookieFilePath to use the default locations ($HOME/.netrc and `git config --get http.cookiefile`).
+func NewNetrcCookieFallbackStore(store CredsStore, netrcPath, cookieFilePath string) *NetrcCookieFallbackStore {
+	return &NetrcCookieFallbackStore{CredsStore: store, netrcPath: netrcPath, cookieFilePath: cookieFilePath}
+}
+
+// GetCreds returns credentials for repoURL. It defers to the wrapped CredsStore first, and only consults .netrc and
+// the git cookie file if that returns no credentials for repoURL's host.
+func (s *NetrcCookieFallbackStore) GetCreds(repoURL string) (string, string, error) {
+	username, password, err := s.CredsStore.GetCreds(repoURL)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get creds from underlying store: %w", err)
+	}
+	if username != "" || password != "" {
+		return username, password, nil
+	}
+
+	host, err := hostOf(repoURL)
+	if err != nil {
+		// Not a URL we can key .netrc/cookie lookups on (e.g. an SSH scp-like address); nothing to fall back to.
+		return "", "", nil
+	}
+
+	if username, password, ok := s.lookupNetrc(host); ok {
+		return username, password, nil
+	}
+	if username, password, ok := s.lookupCookieFile(host); ok {
+		return username, password, nil
+	}
+	return "", "", nil
+}
+
+func (s *NetrcCookieFallbackStore) lookupNetrc(host string) (string, string, bool) {
+	netrcPath := s.netrcPath
+	if netrcPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", false
+		}
+		netrcPath = filepath.Join(home, ".netrc")
+	}
+
+	file, err := os.Open(netrcPath)
+	if err != nil {
+		return "", "", false
+	}
+	defer file.Close()
+
+	return parseNetrc(file, host)
+}
+
+// parseNetrc implements the subset of the .netrc grammar needed for host lookups: whitespace-separated
+// machine/default/login/password tokens. The first "machine <host>" or "default" entry matching host wins, mirroring
+// how curl and git-credential-netrc resolve entries.
+func parseNetrc(r *os.File, host string) (string, string, bool) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+
+	var tokens []string
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Text())
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] != "machine" && tokens[i] != "default" {
+			continue
+		}
+		entryHost := host
+		next := i + 1
+		if tokens[i] == "machine" {
+			if next >= len(tokens) {
+				break
+			}
+			entryHost = tokens[next]
+			next++
+		}
+		if entryHost != host {
+			continue
+		}
+
+		var login, password string
+		for next+1 < len(tokens) && tokens[next] != "machine" && tokens[next] != "default" {
+			switch tokens[next] {
+			case "login":
+				login = tokens[next+1]
+				next += 2
+			case "password":
+				password = tokens[next+1]
+				next += 2
+			default:
+				next++
+			}
+		}
+		if login != "" || password != "" {
+			return login, password, true
+		}
+	}
+	return "", "", false
+}
+
+func (s *NetrcCookieFallbackStore) lookupCookieFile(host string) (string, string, bool) {
+	cookieFilePath := s.cookieFilePath
+	if cookieFilePath == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), cookieFileLookupTimeout)
+		defer cancel()
+		out, err := RunCommandContext(ctx, "", "git", "config", "--get", "http.cookiefile")
+		if err != nil {
+			return "", "", false
+		}
+		cookieFilePath = strings.TrimSpace(out)
+	}
+	if cookieFilePath == "" {
+		return "", "", false
+	}
+
+	file, err := os.Open(cookieFilePath)
+	if err != nil {
+		return "", "", false
+	}
+	defer file.Close()
+
+	return parseCookieFile(file, host)
+}
+
+// parseCookieFile reads a Netscape-format cookie file (as produced by `git credential-cache` helpers and
+// Gerrit/Google Source's gitcookies) and returns the first cookie matching host, honoring the "site-wide" leading
+// dot convention (".example.com" matches "foo.example.com" as well as "example.com").
+func parseCookieFile(r *os.File, host string) (string, string, bool) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+		domain, name, value := fields[0], fields[5], fields[6]
+		if !cookieDomainMatches(domain, host) {
+			continue
+		}
+		// Gerrit/Google Source gitcookies store "login.cookie" style values as "username=password"; anything else
+		// is treated as a bearer-style password with an empty username.
+		if idx := strings.Index(value, "="); idx >= 0 && name == "o" {
+			return value[:idx], value[idx+1:], true
+		}
+		return "", value, true
+	}
+	return "", "", false
+}
+
+func cookieDomainMatches(cookieDomain, host string) bool {
+	if strings.HasPrefix(cookieDomain, ".") {
+		return host == cookieDomain[1:] || strings.HasSuffix(host, cookieDomain)
+	}
+	return host == cookieDomain
+}
+
+func hostOf(repoURL string) (string, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse repo url: %w", err)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("repo url %q has no host", repoURL)
+	}
+	return u.Hostname(), nil
+}
@@ -0,0 +1,179 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, name, contents string) *os.File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open temp file: %v", err)
+	}
+	t.Cleanup(func() { _ = file.Close() })
+	return file
+}
+
+func TestParseNetrc(t *testing.T) {
+	tests := []struct {
+		name         string
+		contents     string
+		host         string
+		wantLogin    string
+		wantPassword string
+		wantOK       bool
+	}{
+		{
+			name:         "matching machine entry",
+			contents:     "machine example.com login alice password hunter2",
+			host:         "example.com",
+			wantLogin:    "alice",
+			wantPassword: "hunter2",
+			wantOK:       true,
+		},
+		{
+			name:     "non-matching machine entry",
+			contents: "machine other.com login alice password hunter2",
+			host:     "example.com",
+			wantOK:   false,
+		},
+		{
+			name:         "default entry used when no machine matches",
+			contents:     "machine other.com login bob password nope\ndefault login alice password hunter2",
+			host:         "example.com",
+			wantLogin:    "alice",
+			wantPassword: "hunter2",
+			wantOK:       true,
+		},
+		{
+			name:         "first matching machine entry wins",
+			contents:     "machine example.com login alice password first\nmachine example.com login alice password second",
+			host:         "example.com",
+			wantLogin:    "alice",
+			wantPassword: "first",
+			wantOK:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeTempFile(t, "netrc", tt.contents)
+			login, password, ok := parseNetrc(file, tt.host)
+			if ok != tt.wantOK {
+				t.Fatalf("parseNetrc() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if login != tt.wantLogin || password != tt.wantPassword {
+				t.Errorf("parseNetrc() = (%q, %q), want (%q, %q)", login, password, tt.wantLogin, tt.wantPassword)
+			}
+		})
+	}
+}
+
+func TestParseCookieFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		contents     string
+		host         string
+		wantLogin    string
+		wantPassword string
+		wantOK       bool
+	}{
+		{
+			name:         "gerrit o cookie is split into username=password",
+			contents:     "gerrit.example.com\tTRUE\t/\tTRUE\t0\to\talice=hunter2",
+			host:         "gerrit.example.com",
+			wantLogin:    "alice",
+			wantPassword: "hunter2",
+			wantOK:       true,
+		},
+		{
+			name:         "ordinary cookie ending in o is not treated as username=password",
+			contents:     "example.com\tTRUE\t/\tTRUE\t0\tfoo\talice=hunter2",
+			host:         "example.com",
+			wantLogin:    "",
+			wantPassword: "alice=hunter2",
+			wantOK:       true,
+		},
+		{
+			name:         "leading-dot domain matches subdomain",
+			contents:     ".example.com\tTRUE\t/\tTRUE\t0\to\talice=hunter2",
+			host:         "foo.example.com",
+			wantLogin:    "alice",
+			wantPassword: "hunter2",
+			wantOK:       true,
+		},
+		{
+			name:         "comment and blank lines are skipped",
+			contents:     "# comment\n\nexample.com\tTRUE\t/\tTRUE\t0\to\talice=hunter2",
+			host:         "example.com",
+			wantLogin:    "alice",
+			wantPassword: "hunter2",
+			wantOK:       true,
+		},
+		{
+			name:     "no matching domain",
+			contents: "other.com\tTRUE\t/\tTRUE\t0\to\talice=hunter2",
+			host:     "example.com",
+			wantOK:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeTempFile(t, "cookies", tt.contents)
+			login, password, ok := parseCookieFile(file, tt.host)
+			if ok != tt.wantOK {
+				t.Fatalf("parseCookieFile() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if login != tt.wantLogin || password != tt.wantPassword {
+				t.Errorf("parseCookieFile() = (%q, %q), want (%q, %q)", login, password, tt.wantLogin, tt.wantPassword)
+			}
+		})
+	}
+}
+
+func TestCookieDomainMatches(t *testing.T) {
+	tests := []struct {
+		cookieDomain string
+		host         string
+		want         bool
+	}{
+		{"example.com", "example.com", true},
+		{".example.com", "foo.example.com", true},
+		{".example.com", "example.com", true},
+		{"example.com", "foo.example.com", false},
+		{"other.com", "example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := cookieDomainMatches(tt.cookieDomain, tt.host); got != tt.want {
+			t.Errorf("cookieDomainMatches(%q, %q) = %v, want %v", tt.cookieDomain, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestHostOf(t *testing.T) {
+	host, err := hostOf("https://example.com/foo/bar.git")
+	if err != nil {
+		t.Fatalf("hostOf() error = %v", err)
+	}
+	if host != "example.com" {
+		t.Errorf("hostOf() = %q, want %q", host, "example.com")
+	}
+
+	if _, err := hostOf("not a url with no host"); err == nil {
+		t.Error("hostOf() expected error for URL with no host, got nil")
+	}
+}
@@ -0,0 +1,41 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// ErrCommandTimedOut wraps context.DeadlineExceeded when a git subprocess started via NewCommandContext or
+// RunCommandContext is killed because its context's deadline elapsed, so callers (and metrics) can use errors.Is to
+// distinguish a timeout from an ordinary git failure.
+var ErrCommandTimedOut = errors.New("git command timed out")
+
+// NewCommandContext is like exec.CommandContext, except the child is placed in its own process group and, on
+// context cancellation, the whole group is killed rather than just the direct child. This matters for git
+// subcommands that spawn helpers (credential helpers, ssh, gpg) which would otherwise be left running as orphans
+// after the parent is killed.
+func NewCommandContext(ctx context.Context, dir, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return cmd
+}
+
+// RunCommandContext runs name with args in dir, honoring ctx for cancellation and deadlines, and returns its
+// combined stdout+stderr. If ctx's deadline elapsed before the command exited, the returned error wraps both
+// ErrCommandTimedOut and ctx.Err(), so callers can distinguish a timeout from any other command failure via
+// errors.Is(err, git.ErrCommandTimedOut) (or errors.Is(err, context.DeadlineExceeded)).
+func RunCommandContext(ctx context.Context, dir, name string, args ...string) (string, error) {
+	cmd := NewCommandContext(ctx, dir, name, args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return string(out), fmt.Errorf("%w: %w: %s", ErrCommandTimedOut, ctx.Err(), out)
+	}
+	return string(out), err
+}
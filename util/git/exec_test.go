@@ -0,0 +1,87 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestRunCommandContext_TimeoutWrapsErrCommandTimedOut guards against a regression where a context deadline expiring
+// mid-command would surface as a bare exec "signal: killed" error instead of something callers can match on with
+// errors.Is.
+func TestRunCommandContext_TimeoutWrapsErrCommandTimedOut(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := RunCommandContext(ctx, "", "sleep", "5")
+	if err == nil {
+		t.Fatal("RunCommandContext() with an expired deadline returned no error")
+	}
+	if !errors.Is(err, ErrCommandTimedOut) {
+		t.Errorf("RunCommandContext() error = %v, want it to wrap ErrCommandTimedOut", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunCommandContext() error = %v, want it to wrap context.DeadlineExceeded", err)
+	}
+}
+
+// TestRunCommandContext_TimeoutKillsProcessGroup guards against orphaned helper processes (credential helpers, ssh,
+// gpg) surviving a timed-out git command: NewCommandContext's Cancel func must kill the whole process group, not just
+// the direct child, so a child that spawns a grandchild can't leave it running after the parent is killed.
+func TestRunCommandContext_TimeoutKillsProcessGroup(t *testing.T) {
+	pidFile := t.TempDir() + "/grandchild.pid"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The child backgrounds a grandchild `sleep`, records its pid, and then sleeps itself; if only the direct child
+	// were killed, the grandchild would keep running after RunCommandContext returns.
+	script := fmt.Sprintf("sleep 5 & echo $! > %s; sleep 5", pidFile)
+	_, err := RunCommandContext(ctx, "", "sh", "-c", script)
+	if !errors.Is(err, ErrCommandTimedOut) {
+		t.Fatalf("RunCommandContext() error = %v, want ErrCommandTimedOut", err)
+	}
+
+	pidBytes, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("failed to read grandchild pid file: %v", err)
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(pidBytes)))
+	if err != nil {
+		t.Fatalf("failed to parse grandchild pid: %v", err)
+	}
+
+	// Give the SIGKILL a moment to land, then confirm the grandchild died. It may linger as a zombie if something
+	// reparented to it hasn't reaped it yet, so check /proc's process state rather than just kill(pid, 0): a zombie
+	// still has a pid table entry but is no longer running.
+	alive := true
+	for i := 0; i < 20 && alive; i++ {
+		alive = processIsRunning(pid)
+		if alive {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	if alive {
+		t.Errorf("grandchild process %d is still running after RunCommandContext's deadline expired", pid)
+	}
+}
+
+// processIsRunning reports whether pid is alive and not a zombie, by reading its /proc state.
+func processIsRunning(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// The state field follows the "(comm)" parenthesized field, which may itself contain spaces or parens, so split
+	// on the last ')' rather than by field index.
+	fields := bytes.Fields(stat[bytes.LastIndexByte(stat, ')')+1:])
+	if len(fields) == 0 {
+		return false
+	}
+	return string(fields[0]) != "Z"
+}